@@ -0,0 +1,179 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+)
+
+const (
+	// eip1271ABI is the interface of contract wallets, see https://eips.ethereum.org/EIPS/eip-1271
+	eip1271ABI = `[{"inputs":[{"internalType":"bytes32","name":"hash","type":"bytes32"},{"internalType":"bytes","name":"signature","type":"bytes"}],"name":"isValidSignature","outputs":[{"internalType":"bytes4","name":"magicValue","type":"bytes4"}],"stateMutability":"view","type":"function"}]`
+	// resultExpiration is how long the answer of a contract wallet is trusted, the owners of a
+	// wallet may change
+	resultExpiration = 5 * time.Minute
+	// codeExpiration is how long an address is known to be, or not to be, a contract
+	codeExpiration = 10 * time.Minute
+	// maxRejections bounds the isValidSignature calls rejected per address in resultExpiration,
+	// further signatures of the address are rejected without a call, so that varying the signature
+	// cannot make one call per request
+	maxRejections = 10
+	callTimeout   = 10 * time.Second
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+	eip1271           = mustParseABI(eip1271ABI)
+)
+
+func mustParseABI(def string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// Verifier checks that an address signed a hash. The signature of an externally owned account is
+// recovered with ECDSA, and when recovery does not match and the address has code, the address is
+// asked with the EIP-1271 isValidSignature call, so that smart-contract wallets such as Safe are
+// supported
+type Verifier struct {
+	caller bind.ContractCaller
+	// results caches the answers of contract wallets by hash, signature and address
+	results *cache.Cache
+	// contracts caches whether an address has code, rejections counts the rejected calls per address
+	contracts  *cache.Cache
+	rejections *cache.Cache
+}
+
+func NewVerifier(caller bind.ContractCaller) *Verifier {
+	return &Verifier{
+		caller:     caller,
+		results:    cache.New(resultExpiration, 2*resultExpiration),
+		contracts:  cache.New(codeExpiration, 2*codeExpiration),
+		rejections: cache.New(resultExpiration, 2*resultExpiration),
+	}
+}
+
+// Verify checks the signature of hash by signer. It returns the public key of signer when it is an
+// externally owned account, and nil when it is a contract wallet
+func (v *Verifier) Verify(ctx context.Context, hash []byte, sig []byte, signer common.Address) (*ecdsa.PublicKey, error) {
+	if pubKey := recoverPubkey(hash, sig); pubKey != nil && crypto.PubkeyToAddress(*pubKey) == signer {
+		return pubKey, nil
+	}
+	if v == nil || v.caller == nil {
+		return nil, errors.Wrap(ErrInvalidSignature, "address mismatch")
+	}
+
+	key := crypto.Keccak256Hash(hash, sig, signer.Bytes()).Hex()
+	if valid, ok := v.results.Get(key); ok {
+		if valid.(bool) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(ErrInvalidSignature, "rejected by contract wallet")
+	}
+
+	isContract, err := v.isContract(ctx, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "get code of signer")
+	}
+	if !isContract {
+		return nil, errors.Wrap(ErrInvalidSignature, "address mismatch")
+	}
+	if rejected, ok := v.rejections.Get(signer.Hex()); ok && rejected.(int) >= maxRejections {
+		return nil, errors.Wrap(ErrInvalidSignature, "too many signatures rejected by contract wallet, retry later")
+	}
+
+	valid, err := v.isValidSignature(ctx, hash, sig, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "call isValidSignature")
+	}
+	v.results.Set(key, valid, cache.DefaultExpiration)
+	if !valid {
+		// The first rejection starts the window, the count does not extend it
+		if v.rejections.Add(signer.Hex(), 1, cache.DefaultExpiration) != nil {
+			_, _ = v.rejections.IncrementInt(signer.Hex(), 1)
+		}
+		return nil, errors.Wrap(ErrInvalidSignature, "address mismatch and not accepted by a contract wallet")
+	}
+	return nil, nil
+}
+
+// IsContract tells whether address has code, that is whether it signs as a contract wallet
+func (v *Verifier) IsContract(ctx context.Context, address common.Address) (bool, error) {
+	if v == nil || v.caller == nil {
+		return false, errors.New("no chain client to get the code of the address")
+	}
+	return v.isContract(ctx, address)
+}
+
+// isContract tells whether signer has code, an externally owned account cannot be a contract wallet
+func (v *Verifier) isContract(ctx context.Context, signer common.Address) (bool, error) {
+	if isContract, ok := v.contracts.Get(signer.Hex()); ok {
+		return isContract.(bool), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	code, err := v.caller.CodeAt(ctx, signer, nil)
+	if err != nil {
+		return false, err
+	}
+	isContract := len(code) > 0
+	v.contracts.Set(signer.Hex(), isContract, cache.DefaultExpiration)
+	return isContract, nil
+}
+
+// recoverPubkey returns the public key of a 65 bytes [R || S || V] signature, V being 0/1 or 27/28
+func recoverPubkey(hash []byte, sig []byte) *ecdsa.PublicKey {
+	if len(sig) != crypto.SignatureLength {
+		return nil
+	}
+	normalized := append([]byte{}, sig...)
+	if normalized[64] >= 27 {
+		normalized[64] -= 27
+	}
+	pubKey, err := crypto.SigToPub(hash, normalized)
+	if err != nil {
+		return nil
+	}
+	return pubKey
+}
+
+// isValidSignature asks the signer, a contract wallet
+func (v *Verifier) isValidSignature(ctx context.Context, hash []byte, sig []byte, signer common.Address) (bool, error) {
+	if len(hash) != common.HashLength {
+		return false, nil
+	}
+	data, err := eip1271.Pack("isValidSignature", common.BytesToHash(hash), sig)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	out, err := v.caller.CallContract(ctx, ethereum.CallMsg{To: &signer, Data: data}, nil)
+	if err != nil {
+		// A contract rejecting the signature may revert instead of returning another value
+		if strings.Contains(err.Error(), "revert") {
+			return false, nil
+		}
+		return false, err
+	}
+	// Calling an account without code succeeds with no output
+	return len(out) >= 4 && bytes.Equal(out[:4], eip1271MagicValue), nil
+}
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Amount is an arbitrary-precision integer amount, such as a price, a fee or a balance in neuron.
+// It is encoded as a decimal string in JSON, YAML and SQL so that it never overflows, and is stored
+// in DECIMAL(65,0) columns so that the database can aggregate it. The zero value is 0
+type Amount struct {
+	v *big.Int
+}
+
+func NewAmount(v int64) Amount {
+	return Amount{v: big.NewInt(v)}
+}
+
+// NewAmountFromBig copies v, a nil v is 0
+func NewAmountFromBig(v *big.Int) Amount {
+	if v == nil {
+		return NewAmount(0)
+	}
+	return Amount{v: new(big.Int).Set(v)}
+}
+
+// ParseAmount parses a base 10 integer
+func ParseAmount(s string) (Amount, error) {
+	v, ok := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("invalid amount: %q", s)
+	}
+	return Amount{v: v}, nil
+}
+
+// Big returns a copy of the amount
+func (a Amount) Big() *big.Int {
+	if a.v == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(a.v)
+}
+
+func (a Amount) String() string {
+	if a.v == nil {
+		return "0"
+	}
+	return a.v.String()
+}
+
+func (a Amount) Sign() int {
+	if a.v == nil {
+		return 0
+	}
+	return a.v.Sign()
+}
+
+func (a Amount) Cmp(b Amount) int {
+	return a.Big().Cmp(b.Big())
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return Amount{v: new(big.Int).Add(a.Big(), b.Big())}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{v: new(big.Int).Sub(a.Big(), b.Big())}
+}
+
+func (a Amount) MulInt64(n int64) Amount {
+	return Amount{v: new(big.Int).Mul(a.Big(), big.NewInt(n))}
+}
+
+// TokenFee prices count tokens at price per unit tokens, so that prices can be quoted per million
+// tokens for example. A fractional fee is rounded up, a unit below 1 counts as 1
+func TokenFee(price Amount, count int64, unit int64) Amount {
+	fee := new(big.Int).Mul(price.Big(), big.NewInt(count))
+	if unit <= 1 {
+		return Amount{v: fee}
+	}
+	d := big.NewInt(unit)
+	fee.Add(fee, new(big.Int).Sub(d, big.NewInt(1)))
+	return Amount{v: fee.Div(fee, d)}
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*a = Amount{}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) MarshalYAML() (interface{}, error) {
+	return a.String(), nil
+}
+
+// UnmarshalYAML accepts a quoted or plain integer, plain integers are read from their text so that
+// large values are not rounded
+func (a *Amount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal string, which MySQL converts exactly to DECIMAL
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *Amount) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = Amount{}
+		return nil
+	case int64:
+		*a = NewAmount(v)
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	default:
+		return fmt.Errorf("unsupported amount type %T", value)
+	}
+}
+
+// scanString also reads the DECIMAL values with a zero fraction returned by aggregations
+func (a *Amount) scanString(s string) error {
+	if whole, frac, ok := strings.Cut(s, "."); ok && strings.Trim(frac, "0") == "" {
+		s = whole
+	}
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
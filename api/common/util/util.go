@@ -84,6 +84,13 @@ func ConvertToBigInt(value interface{}) (*big.Int, error) {
 			return nil, fmt.Errorf("nil pointer to big.Int")
 		}
 		result.Set(v)
+	case Amount:
+		result.Set(v.Big())
+	case *Amount:
+		if v == nil {
+			return nil, fmt.Errorf("nil pointer to Amount")
+		}
+		result.Set(v.Big())
 	default:
 		return nil, fmt.Errorf("unsupported type: %T", value)
 	}
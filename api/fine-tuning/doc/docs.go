@@ -67,7 +67,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "This endpoint allows you to create a fine-tuning task",
+                "description": "This endpoint allows you to create a fine-tuning task. Contract wallets are not supported, the model key is encrypted to the public key of the user",
                 "tags": [
                     "task"
                 ],
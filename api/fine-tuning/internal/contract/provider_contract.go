@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/0glabs/0g-serving-broker/common/log"
+	"github.com/0glabs/0g-serving-broker/common/signature"
 	"github.com/0glabs/0g-serving-broker/fine-tuning/config"
 	"github.com/0glabs/0g-serving-broker/fine-tuning/contract"
 	"github.com/ethereum/go-ethereum/common"
@@ -12,7 +13,9 @@ import (
 type ProviderContract struct {
 	Contract        *contract.ServingContract
 	ProviderAddress string
-	logger          log.Logger
+	// SignatureVerifier checks user signatures, including those of contract wallets
+	SignatureVerifier *signature.Verifier
+	logger            log.Logger
 }
 
 func NewProviderContract(conf *config.Config, logger log.Logger) (*ProviderContract, error) {
@@ -25,9 +28,10 @@ func NewProviderContract(conf *config.Config, logger log.Logger) (*ProviderContr
 		return nil, err
 	}
 	return &ProviderContract{
-		Contract:        contract,
-		ProviderAddress: wallets.Default().Address(),
-		logger:          logger,
+		Contract:          contract,
+		ProviderAddress:   wallets.Default().Address(),
+		SignatureVerifier: signature.NewVerifier(contract.Client.Client),
+		logger:            logger,
 	}, nil
 }
 
@@ -29,6 +29,10 @@ func (c *Ctrl) CreateTask(ctx context.Context, task *schema.Task) (*uuid.UUID, e
 		return nil, err
 	}
 
+	if err := c.validateUserWallet(ctx, task.UserAddress); err != nil {
+		return nil, err
+	}
+
 	c.taskMutex.Lock()
 	defer c.taskMutex.Unlock()
 
@@ -69,14 +73,14 @@ func (c *Ctrl) CreateTask(ctx context.Context, task *schema.Task) (*uuid.UUID, e
 }
 
 func (c *Ctrl) CancelTask(ctx context.Context, task *schema.Task) error {
-	if err := c.validateSignature(task); err != nil {
+	if err := c.validateSignature(ctx, task); err != nil {
 		return err
 	}
 
 	return c.db.CancelTask(task.ID, task.UserAddress)
 }
 
-func (*Ctrl) validateSignature(task *schema.Task) error {
+func (c *Ctrl) validateSignature(ctx context.Context, task *schema.Task) error {
 	id, err := task.ID.MarshalBinary()
 	if err != nil {
 		return err
@@ -88,24 +92,12 @@ func (*Ctrl) validateSignature(task *schema.Task) error {
 	if err != nil {
 		return err
 	}
-
-	if len(sigBytes) != 65 {
-		return fmt.Errorf("invalid signature length %d, expected 65", len(sigBytes))
+	if !common.IsHexAddress(task.UserAddress) {
+		return fmt.Errorf("invalid user address %s", task.UserAddress)
 	}
 
-	if sigBytes[64] != 27 && sigBytes[64] != 28 {
-		return fmt.Errorf("invalid recovery ID (V): got %d", sigBytes[64])
-	}
-
-	sigBytes[64] -= 27
-	pubKey, err := crypto.SigToPub(hash, sigBytes)
-	if err != nil {
-		return err
-	}
-
-	recoveredAddress := crypto.PubkeyToAddress(*pubKey)
-	if recoveredAddress.Hex() != task.UserAddress {
-		return errors.New("signature verification failed: address mismatch")
+	if _, err := c.contract.SignatureVerifier.Verify(ctx, hash, sigBytes, common.HexToAddress(task.UserAddress)); err != nil {
+		return errors.Wrap(err, "signature verification failed")
 	}
 
 	return nil
@@ -155,6 +147,22 @@ func (c *Ctrl) validateProviderSigner(ctx context.Context, userAddressHex string
 	return nil
 }
 
+// validateUserWallet rejects the tasks of contract wallets. The model key is encrypted to the
+// public key recovered from the signature of the user, and a contract wallet has none
+func (c *Ctrl) validateUserWallet(ctx context.Context, userAddressHex string) error {
+	if !common.IsHexAddress(userAddressHex) {
+		return fmt.Errorf("invalid user address %s", userAddressHex)
+	}
+	isContract, err := c.contract.SignatureVerifier.IsContract(ctx, common.HexToAddress(userAddressHex))
+	if err != nil {
+		return errors.Wrap(err, "get code of user address")
+	}
+	if isContract {
+		return errors.New("contract wallets are not supported for fine-tuning")
+	}
+	return nil
+}
+
 func (c *Ctrl) validateNoUnfinishedTasks(task *schema.Task) error {
 	count, err := c.db.UnFinishedTaskCount(task.UserAddress)
 	if err != nil {
@@ -15,7 +15,7 @@ import (
 
 // CreateTask
 //
-//	@Description  This endpoint allows you to create a fine-tuning task
+//	@Description  This endpoint allows you to create a fine-tuning task. Contract wallets are not supported, the model key is encrypted to the public key of the user
 //	@ID			createTask
 //	@Tags		task
 //	@Router		/user/{userAddress}/task [post]
@@ -219,7 +219,7 @@ func (s *Setup) verify(ctx context.Context, tokenSize, trainEpochs int64, task *
 	}
 
 	messageHash := s.getHash(fee, task.DatasetHash, userAddress, nonce)
-	return s.verifySignature(task.Signature, messageHash, userAddress, task)
+	return s.verifySignature(ctx, task.Signature, messageHash, userAddress, task)
 }
 
 func (s *Setup) verifyProviderBalance(ctx context.Context) error {
@@ -262,29 +262,22 @@ func (s *Setup) getHash(
 	return prefixedMsg
 }
 
-func (s *Setup) verifySignature(signature string, messageHash common.Hash, userAddress common.Address, task *db.Task) error {
+func (s *Setup) verifySignature(ctx context.Context, signature string, messageHash common.Hash, userAddress common.Address, task *db.Task) error {
 	sigBytes, err := hexutil.Decode(signature)
 	if err != nil {
 		s.logger.Errorf("invalid signature format: %v", err)
 		return errSignature
 	}
 
-	if len(sigBytes) != 65 {
-		s.logger.Errorf("invalid signature length: %d", len(sigBytes))
-		return errSignature
-	}
-
-	v1 := sigBytes[64] - 27
-	pubKey, err := crypto.SigToPub(messageHash.Bytes(), append(sigBytes[:64], v1))
+	pubKey, err := s.contract.SignatureVerifier.Verify(ctx, messageHash.Bytes(), sigBytes, userAddress)
 	if err != nil {
-		s.logger.Errorf("failed to recover public key: %v", err)
+		s.logger.Errorf("signature verification failed: %v", err)
 		return errSignature
-
 	}
-
-	recoveredAddress := crypto.PubkeyToAddress(*pubKey)
-	if !bytes.EqualFold([]byte(recoveredAddress.Hex()), []byte(userAddress.Hex())) {
-		s.logger.Errorf("signature verification failed")
+	if pubKey == nil {
+		// A contract wallet has no public key of its own, and the key sent with the task is not bound
+		// to the wallet, so the model key would be encrypted to a key the client chose
+		s.logger.Errorf("task %s is signed by contract wallet %s, contract wallets cannot receive the model key", task.ID, userAddress.Hex())
 		return errSignature
 	}
 
@@ -80,7 +80,10 @@ func Main() {
 		panic(err)
 	}
 
-	ctrl := ctrl.New(db, contract, conf, nil, teeService, logger)
+	ctrl, err := ctrl.New(db, contract, conf, nil, teeService, logger)
+	if err != nil {
+		panic(err)
+	}
 
 	settlementProcessor := event.NewSettlementProcessor(ctrl, conf.Interval.SettlementProcessor, conf.Interval.ForceSettlementProcessor, conf.Monitor.Enable, logger)
 	if err := mgr.Add(settlementProcessor); err != nil {
@@ -22,7 +22,7 @@ import (
 )
 
 //go:generate swag fmt
-//go:generate swag init --dir ./,../../ --output ../../doc
+//go:generate swag init --dir ./,../../,../../../common/util --output ../../doc
 
 //	@title			0G Serving Provider Broker API
 //	@version		0.1.0
@@ -31,6 +31,16 @@ import (
 //	@BasePath		/v1
 //	@in				header
 
+//	@securityDefinitions.apikey	AdminToken
+//	@in							header
+//	@name						Authorization
+//	@description				"Bearer <token>", with an admin token of the read-only or operator role from the configuration
+
+//	@securityDefinitions.apikey	AdminSignature
+//	@in							header
+//	@name						Admin-Signature
+//	@description				Personal signature by an admin signer of the method, the request URI, the Admin-Timestamp header and the hex SHA-256 of the body, separated by new lines. The Admin-Timestamp header, in unix seconds, is sent along. The provider signs as operator
+
 func Main() {
 	config := cfg.GetConfig()
 	logger, err := log.GetLogger(config.Logger)
@@ -42,7 +52,7 @@ func Main() {
 	if err != nil {
 		panic(err)
 	}
-	if err := db.Migrate(); err != nil {
+	if err := db.Migrate(config.Services); err != nil {
 		panic(err)
 	}
 
@@ -89,7 +99,10 @@ func Main() {
 		}
 	}
 
-	ctrl := ctrl.New(db, contract, config, svcCache, teeService, logger)
+	ctrl, err := ctrl.New(db, contract, config, svcCache, teeService, logger)
+	if err != nil {
+		panic(err)
+	}
 
 	if err := ctrl.SyncUserAccounts(ctx); err != nil {
 		panic(err)
@@ -1,23 +1,152 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/0glabs/0g-serving-broker/common/config"
+	"github.com/0glabs/0g-serving-broker/common/util"
 	"gopkg.in/yaml.v2"
 )
 
 type Service struct {
-	ServingURL       string            `yaml:"servingUrl"`
-	TargetURL        string            `yaml:"targetUrl"`
-	InputPrice       int64             `yaml:"inputPrice"`
-	OutputPrice      int64             `yaml:"outputPrice"`
+	ServingURL  string      `yaml:"servingUrl"`
+	TargetURL   string      `yaml:"targetUrl"`
+	InputPrice  util.Amount `yaml:"inputPrice"`
+	OutputPrice util.Amount `yaml:"outputPrice"`
+	// PriceUnit is the number of tokens the prices are quoted for, e.g. 1000000 for prices per
+	// million tokens. Fees are rounded up to a whole neuron per request, it defaults to 1
+	PriceUnit        int64             `yaml:"priceUnit"`
 	Type             string            `yaml:"type"`
 	ModelType        string            `yaml:"model"`
 	Verifiability    string            `yaml:"verifiability"`
 	AdditionalSecret map[string]string `yaml:"additionalSecret"`
+	// Tokenizer is the path to the model's tokenizer.json, used to count input tokens before
+	// the request reaches the model. The byte length of the request is used when it is empty
+	Tokenizer string `yaml:"tokenizer"`
+	// Transport tunes the pooled HTTP transport shared by every request to the backend
+	Transport Transport `yaml:"transport"`
+	// Replicas lists the backends serving the model. When empty, TargetURL is the only replica,
+	// otherwise TargetURL is set to the first replica
+	Replicas      []Replica     `yaml:"replicas"`
+	LoadBalancing LoadBalancing `yaml:"loadBalancing"`
+	// ClampMaxTokens caps the output tokens of completion requests to what the balance of the user
+	// pays for at OutputPrice, max_tokens is rewritten or set in the forwarded body
+	ClampMaxTokens bool `yaml:"clampMaxTokens"`
+	// MaxOutputTokens is the largest output of the model. A request without max_tokens is left
+	// as is when the balance pays for more, as backends reject a max_tokens above the context
+	MaxOutputTokens int64 `yaml:"maxOutputTokens"`
+}
+
+// TokenPrices returns the prices per token published in the contract, which has no price unit.
+// Fractional prices are rounded up
+func (s Service) TokenPrices() (util.Amount, util.Amount) {
+	return util.TokenFee(s.InputPrice, 1, s.PriceUnit), util.TokenFee(s.OutputPrice, 1, s.PriceUnit)
+}
+
+// RateLimit bounds what one user address can send, 0 means unlimited
+type RateLimit struct {
+	RequestsPerMinute int64 `yaml:"requestsPerMinute"`
+	// TokensPerMinute counts the billed input and output tokens, it is charged once a response completes
+	TokensPerMinute      int64 `yaml:"tokensPerMinute"`
+	MaxConcurrentStreams int64 `yaml:"maxConcurrentStreams"`
+}
+
+// Admission configures the queue in front of the backend of every service, it is disabled when
+// MaxInFlight is 0
+type Admission struct {
+	MaxInFlight int `yaml:"maxInFlight"`
+	// MaxQueueLength requests wait for a slot, the next ones are rejected at once
+	MaxQueueLength int           `yaml:"maxQueueLength"`
+	QueueTimeout   time.Duration `yaml:"queueTimeout"`
+	// UserPriorities ranks the queued requests by user address, higher first. Users not listed have priority 0
+	UserPriorities map[string]int `yaml:"userPriorities"`
+}
+
+type SIWE struct {
+	// Domain is expected in the messages, the host of the serving URL of the primary service when empty
+	Domain string `yaml:"domain"`
+	// CredentialLifetime bounds the lifetime of the issued session tokens
+	CredentialLifetime time.Duration `yaml:"credentialLifetime"`
+}
+
+// Roles of the management API
+const (
+	// RoleReadOnly lists accounts and requests
+	RoleReadOnly = "read-only"
+	// RoleOperator can also settle fees, sync accounts and change rate limits
+	RoleOperator = "operator"
+)
+
+// AdminAuth configures who may call the management API, either with a static bearer token or with
+// a request signed by an Ethereum key
+type AdminAuth struct {
+	Tokens  []AdminToken  `yaml:"tokens"`
+	Signers []AdminSigner `yaml:"signers"`
+	// SignatureMaxAge bounds the clock skew of signed requests, a signature is accepted only once
+	SignatureMaxAge time.Duration `yaml:"signatureMaxAge"`
+}
+
+type AdminToken struct {
+	Token string `yaml:"token"`
+	Role  string `yaml:"role"`
+}
+
+type AdminSigner struct {
+	Address string `yaml:"address"`
+	Role    string `yaml:"role"`
+}
+
+type Replica struct {
+	URL string `yaml:"url"`
+	// Weight is the share of requests sent to the replica relative to the others, defaulting to 1
+	Weight int `yaml:"weight"`
+}
+
+const (
+	PolicyRoundRobin    = "round-robin"
+	PolicyLeastInFlight = "least-inflight"
+	PolicyLeastQueue    = "least-queue"
+)
+
+type LoadBalancing struct {
+	// Policy is round-robin (default), least-inflight or least-queue
+	Policy      string      `yaml:"policy"`
+	HealthCheck HealthCheck `yaml:"healthCheck"`
+}
+
+// HealthCheck configures the periodic probes of the replicas, they are disabled when Path is empty
+type HealthCheck struct {
+	// Path is requested with GET on every replica, a 2xx response counts as a success
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// FailureThreshold consecutive failures eject a replica, SuccessThreshold consecutive
+	// successes bring it back
+	FailureThreshold int `yaml:"failureThreshold"`
+	SuccessThreshold int `yaml:"successThreshold"`
+	// MetricsPath serves the Prometheus metrics of vLLM, the least-queue policy reads the number of
+	// waiting requests from it on every probe
+	MetricsPath string `yaml:"metricsPath"`
+}
+
+// Transport configures the connections to a backend, zero values fall back to the defaults of the broker
+type Transport struct {
+	DialTimeout time.Duration `yaml:"dialTimeout"`
+	// ResponseHeaderTimeout bounds the wait for the backend to start responding, it does not limit
+	// how long a response keeps streaming
+	ResponseHeaderTimeout time.Duration `yaml:"responseHeaderTimeout"`
+	IdleConnTimeout       time.Duration `yaml:"idleConnTimeout"`
+	MaxConnsPerHost       int           `yaml:"maxConnsPerHost"`
+	MaxIdleConnsPerHost   int           `yaml:"maxIdleConnsPerHost"`
+	// Protocol is http1, http2 (negotiated over TLS) or h2c (HTTP/2 over cleartext)
+	Protocol string `yaml:"protocol"`
+	// MaxRetries is the number of retries of a non-streamed request that fails before any byte
+	// is sent to the client
+	MaxRetries   int           `yaml:"maxRetries"`
+	RetryBackoff time.Duration `yaml:"retryBackoff"`
 }
 
 type Config struct {
@@ -36,7 +165,11 @@ type Config struct {
 		ForceSettlementProcessor int `yaml:"forceSettlementProcessor"`
 		SettlementProcessor      int `yaml:"settlementProcessor"`
 	} `yaml:"interval"`
-	Service  Service         `yaml:"service"`
+	// Service is the primary service, it is set to Services[0] once the config is loaded
+	Service Service `yaml:"service"`
+	// Services lists every model served by this broker, requests are routed by their model field.
+	// When empty, it falls back to the single Service above
+	Services []Service       `yaml:"services"`
 	Networks config.Networks `mapstructure:"networks" yaml:"networks"`
 	Monitor  struct {
 		Enable       bool   `yaml:"enable"`
@@ -49,6 +182,22 @@ type Config struct {
 	ChatCacheExpiration time.Duration        `yaml:"chatCacheExpiration"`
 	NvGPU               bool                 `yaml:"nvGPU"`
 	Logger              *config.LoggerConfig `yaml:"logger"`
+	// SignatureRetention is how long response signatures are kept in the database, 0 keeps them forever
+	SignatureRetention time.Duration `yaml:"signatureRetention"`
+	// UsageRetention is how long the usage records of settled requests are kept, 0 keeps them forever
+	UsageRetention time.Duration `yaml:"usageRetention"`
+	// SigningAlgo is the algorithm of response signatures, ecdsa (personal message) or eip712 (typed data)
+	SigningAlgo string `yaml:"signingAlgo"`
+	// RateLimit is the default limit of every user, it can be overridden per address through the admin API
+	RateLimit RateLimit `yaml:"rateLimit"`
+	// Admission bounds the requests sent to each backend, the others wait in a queue
+	Admission Admission `yaml:"admission"`
+	// AdminAuth protects the management API, the provider key is always an operator
+	AdminAuth AdminAuth `yaml:"adminAuth"`
+	// SessionMaxLifetime bounds ExpiresAt - Timestamp of the session tokens accepted, 0 disables the bound
+	SessionMaxLifetime time.Duration `yaml:"sessionMaxLifetime"`
+	// SIWE configures the Sign-In-With-Ethereum login issuing session tokens
+	SIWE SIWE `yaml:"siwe"`
 }
 
 var (
@@ -73,6 +222,37 @@ func loadConfig(config *Config) error {
 	return yaml.UnmarshalStrict(data, config)
 }
 
+func normalizeServices(config *Config) error {
+	if len(config.Services) == 0 {
+		config.Services = []Service{config.Service}
+	}
+
+	models := make(map[string]struct{}, len(config.Services))
+	for i := range config.Services {
+		svc := &config.Services[i]
+		if _, ok := models[svc.ModelType]; ok {
+			return fmt.Errorf("duplicate model %s in services", svc.ModelType)
+		}
+		models[svc.ModelType] = struct{}{}
+
+		if svc.PriceUnit <= 0 {
+			svc.PriceUnit = 1
+		}
+		if len(svc.Replicas) == 0 {
+			svc.Replicas = []Replica{{URL: svc.TargetURL}}
+		}
+		for j := range svc.Replicas {
+			if svc.Replicas[j].Weight <= 0 {
+				svc.Replicas[j].Weight = 1
+			}
+		}
+		svc.TargetURL = svc.Replicas[0].URL
+	}
+
+	config.Service = config.Services[0]
+	return nil
+}
+
 func GetConfig() *Config {
 	once.Do(func() {
 		instance = &Config{
@@ -114,6 +294,12 @@ func GetConfig() *Config {
 				RequestLength: 40,
 			},
 			ChatCacheExpiration: time.Minute * 20,
+			SignatureRetention:  time.Hour * 24 * 7,
+			UsageRetention:      time.Hour * 24 * 365,
+			SigningAlgo:         "ecdsa",
+			AdminAuth:           AdminAuth{SignatureMaxAge: 5 * time.Minute},
+			SessionMaxLifetime:  time.Hour * 24,
+			SIWE:                SIWE{CredentialLifetime: time.Hour},
 			NvGPU:               false,
 			Logger: &config.LoggerConfig{
 				Format:        "text",
@@ -127,6 +313,10 @@ func GetConfig() *Config {
 			panic(err)
 		}
 
+		if err := normalizeServices(instance); err != nil {
+			panic(err)
+		}
+
 		for _, networkConf := range instance.Networks {
 			networkConf.PrivateKeyStore = config.NewPrivateKeyStore(networkConf)
 		}
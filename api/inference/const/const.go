@@ -3,10 +3,19 @@ package constant
 var (
 	ServicePrefix = "/v1/proxy"
 
+	// Billable routes, each one needs a matching handler in the ctrl route registry
 	TargetRoute = map[string]struct{}{
-		"/chat/completions": {},
+		"/chat/completions":   {},
+		"/completions":        {},
+		"/embeddings":         {},
+		"/images/generations": {},
 	}
 
+	// Prefix of the free route used to fetch the response signature
+	SignatureRoutePrefix = "/signature/"
+	// Prefix of the free route used to fetch the response signature by the request hash instead of the chat id
+	SignatureByRequestRoutePrefix = "/signature/request/"
+
 	// Keep this as to remove duplicate headers from incoming request
 	RequestMetaDataDuplicate = map[string]struct{}{
 		"Address":           {},
@@ -27,6 +36,12 @@ var (
 		"Session-Signature": {},
 	}
 
+	// Prefix of the API keys minted by the broker, sent as "Authorization: Bearer <key>"
+	APIKeyPrefix = "sk-0g-"
+
+	// Response header carrying the output token cap applied from the balance of the user
+	MaxTokensCapHeader = "Max-Tokens-Cap"
+
 	// Should align with the topUpTriggerThreshold in the client sdk
 	SettleTriggerThreshold = int64(1000000)
 
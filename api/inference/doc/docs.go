@@ -1,4 +1,4 @@
-// Code generated by swaggo/swag. DO NOT EDIT
+// Package doc Code generated by swaggo/swag. DO NOT EDIT
 package doc
 
 import "github.com/swaggo/swag"
@@ -15,6 +15,105 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api-key": {
+            "get": {
+                "description": "This endpoint allows a user to list its own API keys, with their spending. It is authenticated with the Address, Session-Token and Session-Signature headers of a session, not with an API key",
+                "tags": [
+                    "apiKey"
+                ],
+                "operationId": "listUserAPIKey",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIKeyList"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "This endpoint allows a user to mint an API key billed to its account. The grant is a JSON string of ctrl.APIKeyGrant, signed by the user like a session token. The key is returned once and is sent as \"Authorization: Bearer \u003ckey\u003e\" to the proxy",
+                "tags": [
+                    "apiKey"
+                ],
+                "operationId": "createAPIKey",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.CreatedAPIKey"
+                        }
+                    }
+                }
+            }
+        },
+        "/api-key/revoke": {
+            "post": {
+                "description": "This endpoint allows a user to revoke one of its API keys. The revocation is a JSON string of ctrl.APIKeyRevocation, signed by the user like a session token",
+                "tags": [
+                    "apiKey"
+                ],
+                "operationId": "revokeUserAPIKey",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.RevokeAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/api-key/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows an operator to revoke any API key. The operator role is required",
+                "tags": [
+                    "apiKey"
+                ],
+                "operationId": "revokeAPIKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
         "/quote": {
             "get": {
                 "description": "This endpoint allows you to get a quote",
@@ -61,7 +160,15 @@ const docTemplate = `{
         },
         "/request": {
             "get": {
-                "description": "This endpoint allows you to list requests",
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to list requests. The read-only role is required",
                 "tags": [
                     "request"
                 ],
@@ -101,9 +208,42 @@ const docTemplate = `{
                 }
             }
         },
+        "/session/revoke": {
+            "post": {
+                "description": "This endpoint allows a user to revoke one of its session tokens. The revocation is a JSON string of ctrl.SessionRevocation, signed by the user like a session token",
+                "tags": [
+                    "session"
+                ],
+                "operationId": "revokeSession",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.RevokeSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
         "/settle": {
             "post": {
-                "description": "This endpoint allows you to settle fees for requests from users",
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to settle fees for requests from users. The operator role is required",
                 "tags": [
                     "settle"
                 ],
@@ -115,88 +255,559 @@ const docTemplate = `{
                 }
             }
         },
-        "/sync-account": {
-            "post": {
-                "description": "This endpoint allows you to synchronize information of all accounts from the contract",
-                "tags": [
-                    "user"
-                ],
-                "operationId": "syncUserAccounts",
-                "responses": {
-                    "202": {
-                        "description": "Accepted"
-                    }
+        "/siwe/login": {
+            "post": {
+                "description": "This endpoint exchanges a signed EIP-4361 message for a short-lived session, sent as the Address, Session-Token and Session-Signature headers. The message must use a nonce from /siwe/nonce, the chain ID of the provider, an expiration time and the resource urn:0g:provider:\u003cprovider address\u003e",
+                "tags": [
+                    "session"
+                ],
+                "operationId": "loginWithSIWE",
+                "parameters": [
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.SIWELoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.SIWELoginResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/siwe/nonce": {
+            "get": {
+                "description": "This endpoint issues a nonce for a Sign-In-With-Ethereum login, valid for 5 minutes",
+                "tags": [
+                    "session"
+                ],
+                "operationId": "getSIWENonce",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sync-account": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to synchronize information of all accounts from the contract. The operator role is required",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "syncUserAccounts",
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    }
+                }
+            }
+        },
+        "/usage": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to list the usage records of settled requests, with the totals of the records matching the filters. The read-only role is required",
+                "tags": [
+                    "usage"
+                ],
+                "operationId": "listUsageRecord",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement transaction hash",
+                        "name": "txHash",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settlement batch ID",
+                        "name": "batchId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settled at or after, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Settled before, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 100 by default and at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UsageRecordList"
+                        }
+                    }
+                }
+            }
+        },
+        "/user": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to list all users who have created accounts for your service. The read-only role is required",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "listUserAccount",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserList"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{user}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to get account by user address. The read-only role is required",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "getUserAccount",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.User"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{user}/api-key": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to list the API keys of a user, with their spending. The read-only role is required",
+                "tags": [
+                    "apiKey"
+                ],
+                "operationId": "listAPIKey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIKeyList"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{user}/rate-limit": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to get the effective rate limit of a user and its override, if any. The read-only role is required",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "getUserRateLimit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.UserRateLimit"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to override the default rate limit of a user. Omitted limits keep the default, and 0 removes a limit. The operator role is required",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "putUserRateLimit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UserRateLimit"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    },
+                    {
+                        "AdminSignature": []
+                    }
+                ],
+                "description": "This endpoint allows you to remove the rate limit override of a user, the default rate limit applies again. The operator role is required",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "deleteUserRateLimit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/user/{user}/sync": {
+            "post": {
+                "description": "This endpoint allows you to synchronize information of single account from the contract",
+                "tags": [
+                    "user"
+                ],
+                "operationId": "syncUserAccount",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User address",
+                        "name": "user",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "ctrl.CreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "grant",
+                "signature"
+            ],
+            "properties": {
+                "grant": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.CreatedAPIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "models": {
+                    "description": "Models lists the models the key may call, empty means every model",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "spendingCap": {
+                    "description": "SpendingCap bounds the fees billed to the key in neuron, nil means no cap",
+                    "type": "string"
+                },
+                "spent": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.RevokeAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "revocation",
+                "signature"
+            ],
+            "properties": {
+                "revocation": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.RevokeSessionRequest": {
+            "type": "object",
+            "required": [
+                "revocation",
+                "signature"
+            ],
+            "properties": {
+                "revocation": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.SIWELoginRequest": {
+            "type": "object",
+            "required": [
+                "message",
+                "signature"
+            ],
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.SIWELoginResponse": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "sessionSignature": {
+                    "type": "string"
+                },
+                "sessionToken": {
+                    "type": "string"
                 }
             }
         },
-        "/user": {
-            "get": {
-                "description": "This endpoint allows you to list all users who have created accounts for your service",
-                "tags": [
-                    "user"
-                ],
-                "operationId": "listUserAccount",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/model.UserList"
-                        }
-                    }
+        "ctrl.UserRateLimit": {
+            "type": "object",
+            "properties": {
+                "maxConcurrentStreams": {
+                    "type": "integer"
+                },
+                "override": {
+                    "$ref": "#/definitions/model.UserRateLimit"
+                },
+                "requestsPerMinute": {
+                    "type": "integer"
+                },
+                "tokensPerMinute": {
+                    "type": "integer"
                 }
             }
         },
-        "/user/{user}": {
-            "get": {
-                "description": "This endpoint allows you to get account by user address",
-                "tags": [
-                    "user"
-                ],
-                "operationId": "getUserAccount",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "User address",
-                        "name": "user",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/model.User"
-                        }
+        "model.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "models": {
+                    "description": "Models lists the models the key may call, empty means every model",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "spendingCap": {
+                    "description": "SpendingCap bounds the fees billed to the key in neuron, nil means no cap",
+                    "type": "string"
+                },
+                "spent": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "user": {
+                    "type": "string"
                 }
             }
         },
-        "/user/{user}/sync": {
-            "post": {
-                "description": "This endpoint allows you to synchronize information of single account from the contract",
-                "tags": [
-                    "user"
-                ],
-                "operationId": "syncUserAccount",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "User address",
-                        "name": "user",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "202": {
-                        "description": "Accepted"
+        "model.APIKeyList": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.APIKey"
                     }
+                },
+                "metadata": {
+                    "$ref": "#/definitions/model.ListMeta"
                 }
             }
-        }
-    },
-    "definitions": {
+        },
         "model.ListMeta": {
             "type": "object",
             "properties": {
@@ -208,10 +819,7 @@ const docTemplate = `{
         "model.Request": {
             "type": "object",
             "required": [
-                "fee",
-                "inputFee",
                 "nonce",
-                "outputFee",
                 "requestHash",
                 "serviceName",
                 "signature",
@@ -219,6 +827,10 @@ const docTemplate = `{
                 "userAddress"
             ],
             "properties": {
+                "apiKeyId": {
+                    "description": "Set when the request was authenticated with a broker-issued API key instead of a session token",
+                    "type": "string"
+                },
                 "createdAt": {
                     "type": "string",
                     "readOnly": true
@@ -233,6 +845,10 @@ const docTemplate = `{
                 "inputFee": {
                     "type": "string"
                 },
+                "inputPrice": {
+                    "description": "Unit prices of the service when the request was created, its fee is billed at these prices",
+                    "type": "string"
+                },
                 "nonce": {
                     "type": "string"
                 },
@@ -242,6 +858,13 @@ const docTemplate = `{
                 "outputFee": {
                     "type": "string"
                 },
+                "outputPrice": {
+                    "type": "string"
+                },
+                "priceUnit": {
+                    "description": "PriceUnit is the number of tokens the prices are quoted for",
+                    "type": "integer"
+                },
                 "processed": {
                     "type": "boolean"
                 },
@@ -251,6 +874,10 @@ const docTemplate = `{
                 "serviceName": {
                     "type": "string"
                 },
+                "sessionNonce": {
+                    "description": "Nonce of the session token the request was authorized with, its fees count against the session cap",
+                    "type": "string"
+                },
                 "signature": {
                     "type": "string"
                 },
@@ -261,10 +888,18 @@ const docTemplate = `{
                 "teeSignature": {
                     "type": "string"
                 },
+                "truncated": {
+                    "description": "Set when the client disconnected mid-stream and only the output already streamed was billed",
+                    "type": "boolean"
+                },
                 "updatedAt": {
                     "type": "string",
                     "readOnly": true
                 },
+                "usageSource": {
+                    "description": "Where the billed token counts come from, one of the UsageSource constants",
+                    "type": "string"
+                },
                 "userAddress": {
                     "type": "string"
                 },
@@ -277,7 +912,7 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "fee": {
-                    "type": "integer"
+                    "type": "string"
                 },
                 "items": {
                     "type": "array",
@@ -347,6 +982,107 @@ const docTemplate = `{
                 }
             }
         },
+        "model.UsageRecord": {
+            "type": "object",
+            "properties": {
+                "apiKeyId": {
+                    "type": "string"
+                },
+                "batchId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "fee": {
+                    "type": "string"
+                },
+                "inputCount": {
+                    "type": "integer"
+                },
+                "inputFee": {
+                    "type": "string"
+                },
+                "inputPrice": {
+                    "description": "Unit prices the request was billed at, per PriceUnit tokens",
+                    "type": "string"
+                },
+                "nonce": {
+                    "type": "string"
+                },
+                "outputCount": {
+                    "type": "integer"
+                },
+                "outputFee": {
+                    "type": "string"
+                },
+                "outputPrice": {
+                    "type": "string"
+                },
+                "priceUnit": {
+                    "type": "integer"
+                },
+                "requestHash": {
+                    "type": "string"
+                },
+                "requestedAt": {
+                    "description": "RequestedAt is the creation time of the request",
+                    "type": "string"
+                },
+                "serviceName": {
+                    "type": "string"
+                },
+                "sessionNonce": {
+                    "type": "string"
+                },
+                "settledAt": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "type": "boolean"
+                },
+                "txHash": {
+                    "description": "TxHash is the settleFeesWithTEE transaction, BatchID the settlement round it was sent in",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "usageSource": {
+                    "description": "Where the billed token counts come from, one of the UsageSource constants",
+                    "type": "string"
+                },
+                "userAddress": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UsageRecordList": {
+            "type": "object",
+            "properties": {
+                "fee": {
+                    "type": "string"
+                },
+                "inputCount": {
+                    "description": "Totals of every record matching the options, not only of the page returned",
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UsageRecord"
+                    }
+                },
+                "metadata": {
+                    "$ref": "#/definitions/model.ListMeta"
+                },
+                "outputCount": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.User": {
             "type": "object",
             "required": [
@@ -394,6 +1130,45 @@ const docTemplate = `{
                     "$ref": "#/definitions/model.ListMeta"
                 }
             }
+        },
+        "model.UserRateLimit": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "maxConcurrentStreams": {
+                    "type": "integer"
+                },
+                "requestsPerMinute": {
+                    "type": "integer"
+                },
+                "tokensPerMinute": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminSignature": {
+            "description": "Personal signature by an admin signer of the method, the request URI, the Admin-Timestamp header and the hex SHA-256 of the body, separated by new lines. The Admin-Timestamp header, in unix seconds, is sent along. The provider signs as operator",
+            "type": "apiKey",
+            "name": "Admin-Signature",
+            "in": "header"
+        },
+        "AdminToken": {
+            "description": "\"Bearer \u003ctoken\u003e\", with an admin token of the read-only or operator role from the configuration",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -408,6 +1183,8 @@ var SwaggerInfo = &swag.Spec{
 	Description:      "These APIs allow providers to manage services and user accounts. The host is localhost, and the port is configured in the provider's configuration file, defaulting to 3080.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
+	LeftDelim:        "{{",
+	RightDelim:       "}}",
 }
 
 func init() {
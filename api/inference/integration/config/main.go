@@ -59,6 +59,8 @@ type Config struct {
 		EventAddress string `yaml:"eventAddress,omitempty"`
 	} `yaml:"monitor,omitempty"`
 	ChatCacheExpiration interface{} `yaml:"chatCacheExpiration,omitempty"`
+	SignatureRetention  interface{} `yaml:"signatureRetention,omitempty"`
+	SigningAlgo         string      `yaml:"signingAlgo,omitempty"`
 	NvGPU               bool        `yaml:"nvGPU,omitempty"`
 	Logger              struct {
 		Format        string `yaml:"format,omitempty"`
@@ -1182,6 +1184,12 @@ func mergeConfigs(base, user *Config) {
 	if user.ChatCacheExpiration != nil {
 		base.ChatCacheExpiration = user.ChatCacheExpiration
 	}
+	if user.SignatureRetention != nil {
+		base.SignatureRetention = user.SignatureRetention
+	}
+	if user.SigningAlgo != "" {
+		base.SigningAlgo = user.SigningAlgo
+	}
 	base.NvGPU = user.NvGPU
 }
 
@@ -1366,6 +1374,9 @@ func cleanupPlaceholderFields(config *Config) {
 	if isPlaceholderInterface(config.ChatCacheExpiration) {
 		config.ChatCacheExpiration = nil
 	}
+	if isPlaceholderInterface(config.SignatureRetention) {
+		config.SignatureRetention = nil
+	}
 
 	// Clean service additional secrets
 	if config.Service.AdditionalSecret != nil {
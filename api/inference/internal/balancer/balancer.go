@@ -0,0 +1,249 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/common/log"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+)
+
+// Defaults of config.HealthCheck
+const (
+	defaultInterval         = 10 * time.Second
+	defaultTimeout          = 3 * time.Second
+	defaultFailureThreshold = 3
+	defaultSuccessThreshold = 2
+)
+
+var ErrNoHealthyReplica = errors.New("no healthy replica")
+
+// Replica is one backend of a service
+type Replica struct {
+	URL    string
+	weight int64
+
+	inFlight atomic.Int64
+	// queue is the number of requests waiting in the replica as last probed, -1 when unknown
+	queue   atomic.Int64
+	healthy atomic.Bool
+
+	// current is the smooth weighted round-robin state, guarded by Balancer.mu
+	current int64
+	// failures and successes count consecutive probe results, they are only used by the prober
+	failures  int
+	successes int
+}
+
+// Release marks the request picked on the replica as finished
+func (r *Replica) Release() {
+	r.inFlight.Add(-1)
+}
+
+// Balancer spreads the requests of one service over its replicas. A request keeps the replica it
+// was picked on until it is released, so a stream is served by one replica for its whole lifetime
+type Balancer struct {
+	model    string
+	policy   string
+	health   config.HealthCheck
+	replicas []*Replica
+	mu       sync.Mutex
+	client   *http.Client
+	logger   log.Logger
+}
+
+func New(svc config.Service, logger log.Logger) (*Balancer, error) {
+	policy := svc.LoadBalancing.Policy
+	switch policy {
+	case "":
+		policy = config.PolicyRoundRobin
+	case config.PolicyRoundRobin, config.PolicyLeastInFlight, config.PolicyLeastQueue:
+	default:
+		return nil, fmt.Errorf("load balancing policy %s of model %s is not supported", policy, svc.ModelType)
+	}
+
+	health := svc.LoadBalancing.HealthCheck
+	if health.Interval <= 0 {
+		health.Interval = defaultInterval
+	}
+	if health.Timeout <= 0 {
+		health.Timeout = defaultTimeout
+	}
+	if health.FailureThreshold <= 0 {
+		health.FailureThreshold = defaultFailureThreshold
+	}
+	if health.SuccessThreshold <= 0 {
+		health.SuccessThreshold = defaultSuccessThreshold
+	}
+
+	b := &Balancer{
+		model:  svc.ModelType,
+		policy: policy,
+		health: health,
+		client: &http.Client{Timeout: health.Timeout},
+		logger: logger,
+	}
+	for _, replica := range svc.Replicas {
+		r := &Replica{URL: strings.TrimRight(replica.URL, "/"), weight: int64(replica.Weight)}
+		if r.weight <= 0 {
+			r.weight = 1
+		}
+		r.queue.Store(-1)
+		r.healthy.Store(true)
+		b.replicas = append(b.replicas, r)
+	}
+	if len(b.replicas) == 0 {
+		return nil, fmt.Errorf("model %s has no replica", svc.ModelType)
+	}
+	return b, nil
+}
+
+// Pick selects a healthy replica according to the policy, the caller must release it
+func (b *Balancer) Pick() (*Replica, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var picked *Replica
+	switch b.policy {
+	case config.PolicyLeastInFlight:
+		picked = b.pickLeast(func(r *Replica) int64 { return r.inFlight.Load() })
+	case config.PolicyLeastQueue:
+		picked = b.pickLeast(func(r *Replica) int64 {
+			// The in-flight requests of this broker break ties between probes
+			if queue := r.queue.Load(); queue >= 0 {
+				return queue + r.inFlight.Load()
+			}
+			return r.inFlight.Load()
+		})
+	default:
+		picked = b.pickRoundRobin()
+	}
+	if picked == nil {
+		return nil, errors.Wrapf(ErrNoHealthyReplica, "model %s", b.model)
+	}
+	picked.inFlight.Add(1)
+	return picked, nil
+}
+
+// pickRoundRobin is the smooth weighted round-robin of nginx, which interleaves the replicas
+// instead of sending bursts to the heaviest one
+func (b *Balancer) pickRoundRobin() *Replica {
+	var best *Replica
+	var total int64
+	for _, r := range b.replicas {
+		if !r.healthy.Load() {
+			continue
+		}
+		r.current += r.weight
+		total += r.weight
+		if best == nil || r.current > best.current {
+			best = r
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// pickLeast selects the replica with the lowest load per unit of weight
+func (b *Balancer) pickLeast(load func(*Replica) int64) *Replica {
+	var best *Replica
+	bestScore := math.Inf(1)
+	for _, r := range b.replicas {
+		if !r.healthy.Load() {
+			continue
+		}
+		if score := float64(load(r)) / float64(r.weight); score < bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best
+}
+
+// Run probes the replicas until ctx is done, it returns at once when health checks are disabled
+func (b *Balancer) Run(ctx context.Context) {
+	if b.health.Path == "" && (b.policy != config.PolicyLeastQueue || b.health.MetricsPath == "") {
+		return
+	}
+
+	ticker := time.NewTicker(b.health.Interval)
+	defer ticker.Stop()
+	for {
+		b.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Balancer) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range b.replicas {
+		wg.Add(1)
+		go func(r *Replica) {
+			defer wg.Done()
+			b.probe(ctx, r)
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (b *Balancer) probe(ctx context.Context, r *Replica) {
+	if b.health.Path != "" {
+		err := b.checkHealth(ctx, r)
+		b.record(r, err)
+	}
+	if b.policy == config.PolicyLeastQueue && b.health.MetricsPath != "" {
+		queue, err := b.queueDepth(ctx, r)
+		if err != nil {
+			b.logger.Debugf("read queue depth of replica %s of model %s: %v", r.URL, b.model, err)
+			queue = -1
+		}
+		r.queue.Store(queue)
+	}
+}
+
+func (b *Balancer) checkHealth(ctx context.Context, r *Replica) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL+b.health.Path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// record updates the consecutive probe results and ejects or restores the replica
+func (b *Balancer) record(r *Replica, err error) {
+	if err != nil {
+		r.successes = 0
+		r.failures++
+		if r.healthy.Load() && r.failures >= b.health.FailureThreshold {
+			r.healthy.Store(false)
+			b.logger.Warnf("replica %s of model %s ejected after %d failed health checks: %v", r.URL, b.model, r.failures, err)
+		}
+		return
+	}
+	r.failures = 0
+	r.successes++
+	if !r.healthy.Load() && r.successes >= b.health.SuccessThreshold {
+		r.healthy.Store(true)
+		b.logger.Infof("replica %s of model %s is healthy again", r.URL, b.model)
+	}
+}
@@ -0,0 +1,65 @@
+package balancer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// vllmWaitingMetric is the vLLM gauge of requests waiting to be scheduled
+const vllmWaitingMetric = "vllm:num_requests_waiting"
+
+// queueDepth sums the waiting requests of every model served by the replica
+func (b *Balancer) queueDepth(ctx context.Context, r *Replica) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL+b.health.MetricsPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("metrics returned %s", resp.Status)
+	}
+
+	var total float64
+	found := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, vllmWaitingMetric) {
+			continue
+		}
+		// A sample is "name{labels} value [timestamp]"
+		rest := strings.TrimPrefix(line, vllmWaitingMetric)
+		if rest == "" || (rest[0] != '{' && rest[0] != ' ') {
+			continue
+		}
+		if i := strings.LastIndex(rest, "}"); i >= 0 {
+			rest = rest[i+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		total += value
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("metric %s not found", vllmWaitingMetric)
+	}
+	return int64(math.Round(total)), nil
+}
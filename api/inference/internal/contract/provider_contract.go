@@ -2,6 +2,7 @@ package providercontract
 
 import (
 	"context"
+	"math/big"
 	"os"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 
 	"github.com/0glabs/0g-serving-broker/common/log"
+	"github.com/0glabs/0g-serving-broker/common/signature"
 	"github.com/0glabs/0g-serving-broker/inference/config"
 	"github.com/0glabs/0g-serving-broker/inference/contract"
 )
@@ -16,9 +18,13 @@ import (
 type ProviderContract struct {
 	Contract         *contract.ServingContract
 	ProviderAddress  string
+	ContractAddress  common.Address
+	ChainID          *big.Int
 	LockTime         time.Duration
 	EncryptedPrivKey string
-	logger           log.Logger
+	// SignatureVerifier checks user signatures, including those of contract wallets
+	SignatureVerifier *signature.Verifier
+	logger            log.Logger
 }
 
 func NewProviderContract(conf *config.Config, logger log.Logger) (*ProviderContract, error) {
@@ -38,10 +44,13 @@ func NewProviderContract(conf *config.Config, logger log.Logger) (*ProviderContr
 		return nil, err
 	}
 	return &ProviderContract{
-		Contract:        contract,
-		ProviderAddress: wallets.Default().Address(),
-		LockTime:        time.Duration(lockTime.Int64()) * time.Second,
-		logger:          logger,
+		Contract:          contract,
+		ProviderAddress:   wallets.Default().Address(),
+		ContractAddress:   common.HexToAddress(conf.ContractAddress),
+		ChainID:           contract.Client.Network.ChainID(),
+		LockTime:          time.Duration(lockTime.Int64()) * time.Second,
+		SignatureVerifier: signature.NewVerifier(contract.Client.Client),
+		logger:            logger,
 	}, nil
 }
 
@@ -18,17 +18,19 @@ type TEESettlementData struct {
 	Signature    []byte
 }
 
-func (c *ProviderContract) SettleFeesWithTEE(ctx context.Context, settlements []contract.TEESettlementData) ([]common.Address, error) {
+// SettleFeesWithTEE sends the settlements in one transaction, and returns its hash and the users
+// whose fees were not fully settled
+func (c *ProviderContract) SettleFeesWithTEE(ctx context.Context, settlements []contract.TEESettlementData) (common.Hash, []common.Address, error) {
 	// Execute the actual transaction
 	tx, err := c.Contract.Transact(ctx, nil, "settleFeesWithTEE", settlements)
 	if err != nil {
-		return nil, errors.Wrap(err, "call settleFeesWithTEE")
+		return common.Hash{}, nil, errors.Wrap(err, "call settleFeesWithTEE")
 	}
 	
 	// Wait for transaction receipt
 	receipt, err := c.Contract.WaitForReceipt(ctx, tx.Hash())
 	if err != nil {
-		return nil, errors.Wrap(err, "wait for receipt")
+		return tx.Hash(), nil, errors.Wrap(err, "wait for receipt")
 	}
 	
 	// Parse TEESettlementResult events from logs to determine failed users
@@ -50,5 +52,5 @@ func (c *ProviderContract) SettleFeesWithTEE(ctx context.Context, settlements []
 		}
 	}
 	
-	return failedUsers, nil
+	return tx.Hash(), failedUsers, nil
 }
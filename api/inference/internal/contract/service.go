@@ -2,35 +2,27 @@ package providercontract
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
-	"github.com/0glabs/0g-serving-broker/common/util"
 	"github.com/0glabs/0g-serving-broker/inference/config"
 	"github.com/0glabs/0g-serving-broker/inference/contract"
 )
 
 var ErrServiceNotFound = errors.New("service not found")
 
-func (c *ProviderContract) AddOrUpdateService(ctx context.Context, service config.Service) error {
+func (c *ProviderContract) AddOrUpdateService(ctx context.Context, service config.Service, additionalInfo string) error {
 	c.logger.Infof("[AddOrUpdateService] Starting to add or update service - provider=%s, type=%s, url=%s, model=%s, verifiability=%s",
 		c.ProviderAddress, service.Type, service.ServingURL, service.ModelType, service.Verifiability)
 	
-	c.logger.Infof("[AddOrUpdateService] Price information - inputPrice=%d, outputPrice=%d",
-		service.InputPrice, service.OutputPrice)
+	c.logger.Infof("[AddOrUpdateService] Price information - inputPrice=%s, outputPrice=%s, priceUnit=%d",
+		service.InputPrice, service.OutputPrice, service.PriceUnit)
 	
-	inputPrice, err := util.ConvertToBigInt(service.InputPrice)
-	if err != nil {
-		c.logger.Errorf("[AddOrUpdateService] Failed to convert input price - inputPrice=%d, error=%v", service.InputPrice, err)
-		return errors.Wrap(err, "convert input price")
-	}
-	outputPrice, err := util.ConvertToBigInt(service.OutputPrice)
-	if err != nil {
-		c.logger.Errorf("[AddOrUpdateService] Failed to convert output price - outputPrice=%d, error=%v", service.OutputPrice, err)
-		return errors.Wrap(err, "convert input price")
-	}
+	tokenInputPrice, tokenOutputPrice := service.TokenPrices()
+	inputPrice, outputPrice := tokenInputPrice.Big(), tokenOutputPrice.Big()
 	
 	c.logger.Infof("[AddOrUpdateService] Preparing to send transaction to contract - inputPriceWei=%s, outputPriceWei=%s",
 		inputPrice.String(), outputPrice.String())
@@ -45,7 +37,7 @@ func (c *ProviderContract) AddOrUpdateService(ctx context.Context, service confi
 			Verifiability:  service.Verifiability,
 			InputPrice:     inputPrice,
 			OutputPrice:    outputPrice,
-			AdditionalInfo: c.EncryptedPrivKey,
+			AdditionalInfo: additionalInfo,
 		},
 	)
 
@@ -111,10 +103,31 @@ func (c *ProviderContract) GetService(ctx context.Context) (*contract.Service, e
 	return nil, ErrServiceNotFound
 }
 
-func (c *ProviderContract) SyncService(ctx context.Context, new config.Service) error {
-	c.logger.Infof("[SyncService] Starting to sync service - provider=%s, newURL=%s, newModel=%s, newType=%s, inputPrice=%d, outputPrice=%d",
-		c.ProviderAddress, new.ServingURL, new.ModelType, new.Type, new.InputPrice, new.OutputPrice)
+// ServiceModel describes one model served behind the provider's on-chain service entry
+type ServiceModel struct {
+	Model       string `json:"model"`
+	Type        string `json:"type"`
+	InputPrice  string `json:"inputPrice"`
+	OutputPrice string `json:"outputPrice"`
+}
+
+// ServiceAdditionalInfo is stored in the additionalInfo of the on-chain service when more than
+// one model is served. The contract keeps a single service per provider, so the primary service
+// fills the service fields and every served model is listed here
+type ServiceAdditionalInfo struct {
+	Models []ServiceModel `json:"models"`
+}
+
+func (c *ProviderContract) SyncService(ctx context.Context, services []config.Service) error {
+	new := services[0]
+	c.logger.Infof("[SyncService] Starting to sync service - provider=%s, newURL=%s, newModel=%s, newType=%s, inputPrice=%s, outputPrice=%s, models=%d",
+		c.ProviderAddress, new.ServingURL, new.ModelType, new.Type, new.InputPrice, new.OutputPrice, len(services))
 	
+	additionalInfo, err := c.additionalInfo(services)
+	if err != nil {
+		return errors.Wrap(err, "encode additional info")
+	}
+
 	old, err := c.GetService(ctx)
 	if err != nil && err.Error() != "service not found" {
 		c.logger.Errorf("[SyncService] Failed to get existing service - error=%v", err)
@@ -136,13 +149,13 @@ func (c *ProviderContract) SyncService(ctx context.Context, new config.Service)
 		c.logger.Info("[SyncService] Deleting service: new service URL is empty")
 		return c.DeleteService(ctx)
 	}
-	if old != nil && identicalService(*old, new) {
+	if old != nil && identicalService(*old, new, additionalInfo) {
 		c.logger.Info("[SyncService] Service is identical, no update needed")
 		return nil
 	}
 	
 	c.logger.Info("[SyncService] Preparing to add or update service to contract")
-	if err := c.AddOrUpdateService(ctx, new); err != nil {
+	if err := c.AddOrUpdateService(ctx, new, additionalInfo); err != nil {
 		c.logger.Errorf("[SyncService] Failed to add or update service - error=%v", err)
 		return errors.Wrap(err, "add or update service in contract")
 	}
@@ -151,17 +164,40 @@ func (c *ProviderContract) SyncService(ctx context.Context, new config.Service)
 	return nil
 }
 
-func identicalService(old contract.Service, new config.Service) bool {
+func (c *ProviderContract) additionalInfo(services []config.Service) (string, error) {
+	if len(services) == 1 {
+		return c.EncryptedPrivKey, nil
+	}
+
+	info := ServiceAdditionalInfo{}
+	for _, svc := range services {
+		inputPrice, outputPrice := svc.TokenPrices()
+		info.Models = append(info.Models, ServiceModel{
+			Model:       svc.ModelType,
+			Type:        svc.Type,
+			InputPrice:  inputPrice.String(),
+			OutputPrice: outputPrice.String(),
+		})
+	}
+	bytes, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func identicalService(old contract.Service, new config.Service, additionalInfo string) bool {
 	if old.Model != new.ModelType {
 		return false
 	}
 	if old.Verifiability != new.Verifiability {
 		return false
 	}
-	if old.InputPrice.Int64() != new.InputPrice {
+	inputPrice, outputPrice := new.TokenPrices()
+	if old.InputPrice.Cmp(inputPrice.Big()) != 0 {
 		return false
 	}
-	if old.OutputPrice.Int64() != new.OutputPrice {
+	if old.OutputPrice.Cmp(outputPrice.Big()) != 0 {
 		return false
 	}
 	if old.ServiceType != new.Type {
@@ -170,5 +206,8 @@ func identicalService(old contract.Service, new config.Service) bool {
 	if old.Url != new.ServingURL {
 		return false
 	}
+	if old.AdditionalInfo != additionalInfo {
+		return false
+	}
 	return true
 }
@@ -0,0 +1,164 @@
+package ctrl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+)
+
+// Headers of a signed management request
+const (
+	AdminTimestampHeader = "Admin-Timestamp"
+	AdminSignatureHeader = "Admin-Signature"
+)
+
+// adminAuth holds the credentials of the management API
+type adminAuth struct {
+	tokens  []config.AdminToken
+	signers map[string]string
+	maxAge  time.Duration
+	// seen keeps the signatures accepted within maxAge, so that a captured request cannot be replayed
+	seen *cache.Cache
+}
+
+func validRole(role string) bool {
+	return role == config.RoleReadOnly || role == config.RoleOperator
+}
+
+func newAdminAuth(cfg config.AdminAuth, providerAddress string) (*adminAuth, error) {
+	maxAge := cfg.SignatureMaxAge
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	a := &adminAuth{
+		signers: make(map[string]string, len(cfg.Signers)+1),
+		maxAge:  maxAge,
+		seen:    cache.New(2*maxAge, 2*maxAge),
+	}
+	for _, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, errors.New("admin token is empty")
+		}
+		if !validRole(t.Role) {
+			return nil, fmt.Errorf("admin token role %s is not supported", t.Role)
+		}
+		a.tokens = append(a.tokens, t)
+	}
+	for _, s := range cfg.Signers {
+		if !validRole(s.Role) {
+			return nil, fmt.Errorf("admin signer role %s is not supported", s.Role)
+		}
+		a.signers[strings.ToLower(s.Address)] = s.Role
+	}
+	a.signers[strings.ToLower(providerAddress)] = config.RoleOperator
+	return a, nil
+}
+
+// RoleAllows tells whether role grants the permissions of required
+func RoleAllows(role, required string) bool {
+	switch required {
+	case config.RoleReadOnly:
+		return role == config.RoleReadOnly || role == config.RoleOperator
+	case config.RoleOperator:
+		return role == config.RoleOperator
+	}
+	return false
+}
+
+// AdminRequestMessage is the message signed as a personal message for a management request:
+// the method, the request URI, the unix timestamp in seconds and the hex SHA-256 of the body,
+// separated by new lines
+func AdminRequestMessage(method, requestURI, timestamp string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{strings.ToUpper(method), requestURI, timestamp, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// AuthenticateAdmin returns the role of the caller of the management API, from its bearer token
+// or from the signature of the request
+func (c *Ctrl) AuthenticateAdmin(ctx *gin.Context) (string, error) {
+	if token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer "); ok {
+		for _, t := range c.adminAuth.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+				return t.Role, nil
+			}
+		}
+		return "", errors.New("invalid admin token")
+	}
+
+	signature := ctx.GetHeader(AdminSignatureHeader)
+	timestamp := ctx.GetHeader(AdminTimestampHeader)
+	if signature == "" || timestamp == "" {
+		return "", errors.New("missing admin credentials, send a bearer token or the Admin-Timestamp and Admin-Signature headers")
+	}
+	return c.authenticateSignedRequest(ctx, timestamp, signature)
+}
+
+func (c *Ctrl) authenticateSignedRequest(ctx *gin.Context, timestamp, signature string) (string, error) {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid admin timestamp")
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > c.adminAuth.maxAge || age < -c.adminAuth.maxAge {
+		return "", errors.New("admin signature expired")
+	}
+
+	var body []byte
+	if ctx.Request.Body != nil {
+		body, err = io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return "", errors.Wrap(err, "read request body")
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid admin signature format")
+	}
+	if len(sig) != 65 {
+		return "", errors.New("invalid admin signature length")
+	}
+	sig = append([]byte{}, sig...)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	// A high s or another v would be a second encoding of the same signature, they are rejected so
+	// that a captured request cannot be replayed under a new signature
+	if !crypto.ValidateSignatureValues(sig[64], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64]), true) {
+		return "", errors.New("invalid admin signature values")
+	}
+	message := AdminRequestMessage(ctx.Request.Method, ctx.Request.URL.RequestURI(), timestamp, body)
+	messageHash := accounts.TextHash([]byte(message))
+	pubKey, err := crypto.SigToPub(messageHash, sig)
+	if err != nil {
+		return "", errors.Wrap(err, "recover admin signer")
+	}
+	signer := strings.ToLower(crypto.PubkeyToAddress(*pubKey).Hex())
+	role, ok := c.adminAuth.signers[signer]
+	if !ok {
+		return "", fmt.Errorf("%s is not an admin signer", signer)
+	}
+
+	// The signed message is accepted once per signer, however the signature is encoded
+	if err := c.adminAuth.seen.Add(signer+":"+hexutil.Encode(messageHash), struct{}{}, cache.DefaultExpiration); err != nil {
+		return "", errors.New("admin signature already used")
+	}
+	return role, nil
+}
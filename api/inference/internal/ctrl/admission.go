@@ -0,0 +1,235 @@
+package ctrl
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	"github.com/0glabs/0g-serving-broker/inference/monitor"
+)
+
+// defaultQueueTimeout applies when the admission queue is enabled without a timeout
+const defaultQueueTimeout = 30 * time.Second
+
+// AdmissionError is returned when a request is not admitted to a saturated backend, it is answered
+// with a 503
+type AdmissionError struct {
+	Model      string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("model %s is saturated: %s, retry after %s", e.Model, e.Reason, e.RetryAfter)
+}
+
+// RetryAfterSeconds is the value of the Retry-After header
+func (e *AdmissionError) RetryAfterSeconds() int64 {
+	return int64(math.Max(1, math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// waiter is a queued request, it is admitted when ready is closed
+type waiter struct {
+	priority int
+	seq      uint64
+	index    int
+	ready    chan struct{}
+}
+
+// waiterHeap orders the waiters by priority, then by arrival
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
+
+// admissionQueue bounds the requests in flight to the backend of one service
+type admissionQueue struct {
+	model          string
+	maxInFlight    int
+	maxQueueLength int
+	timeout        time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	waiters  waiterHeap
+	seq      uint64
+}
+
+func newAdmissionQueue(model string, cfg config.Admission) *admissionQueue {
+	return &admissionQueue{
+		model:          model,
+		maxInFlight:    cfg.MaxInFlight,
+		maxQueueLength: cfg.MaxQueueLength,
+		timeout:        durationOrDefault(cfg.QueueTimeout, defaultQueueTimeout),
+	}
+}
+
+// acquire waits for a slot until the queue timeout or the end of ctx. The returned release must be
+// called once the request is done
+func (q *admissionQueue) acquire(ctx context.Context, priority int) (func(), error) {
+	q.mu.Lock()
+	if q.inFlight < q.maxInFlight && len(q.waiters) == 0 {
+		q.inFlight++
+		q.mu.Unlock()
+		return q.releaseFunc(), nil
+	}
+	if len(q.waiters) >= q.maxQueueLength {
+		q.mu.Unlock()
+		q.reject("queue_full")
+		return nil, &AdmissionError{
+			Model:      q.model,
+			Reason:     fmt.Sprintf("%d requests already queued", q.maxQueueLength),
+			RetryAfter: time.Second,
+		}
+	}
+	q.seq++
+	w := &waiter{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	position := q.position(w)
+	q.observeLength()
+	q.mu.Unlock()
+
+	if monitor.AdmissionQueuePosition != nil {
+		monitor.AdmissionQueuePosition.WithLabelValues(q.model).Observe(float64(position))
+	}
+	start := time.Now()
+	defer func() {
+		if monitor.AdmissionWaitDuration != nil {
+			monitor.AdmissionWaitDuration.WithLabelValues(q.model).Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+	var reason string
+	select {
+	case <-w.ready:
+		return q.releaseFunc(), nil
+	case <-timer.C:
+		reason = "timeout"
+	case <-ctx.Done():
+		reason = "canceled"
+	}
+
+	q.mu.Lock()
+	if w.index < 0 {
+		// The slot was handed over while giving up, it is passed on to the next waiter
+		q.mu.Unlock()
+		q.release()
+	} else {
+		heap.Remove(&q.waiters, w.index)
+		q.observeLength()
+		q.mu.Unlock()
+	}
+	q.reject(reason)
+	if reason == "canceled" {
+		return nil, ctx.Err()
+	}
+	return nil, &AdmissionError{
+		Model:      q.model,
+		Reason:     fmt.Sprintf("not admitted within %s", q.timeout),
+		RetryAfter: q.timeout,
+	}
+}
+
+func (q *admissionQueue) releaseFunc() func() {
+	var once sync.Once
+	return func() { once.Do(q.release) }
+}
+
+// release hands the slot over to the first waiter, or frees it
+func (q *admissionQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		q.inFlight--
+		return
+	}
+	w := heap.Pop(&q.waiters).(*waiter)
+	q.observeLength()
+	close(w.ready)
+}
+
+// position is the 1-based rank of w among the waiters, q.mu must be held
+func (q *admissionQueue) position(w *waiter) int {
+	position := 1
+	for _, other := range q.waiters {
+		if other != w && q.waiters.Less(other.index, w.index) {
+			position++
+		}
+	}
+	return position
+}
+
+// observeLength reports the queue length, q.mu must be held
+func (q *admissionQueue) observeLength() {
+	if monitor.AdmissionQueueLength != nil {
+		monitor.AdmissionQueueLength.WithLabelValues(q.model).Set(float64(len(q.waiters)))
+	}
+}
+
+func (q *admissionQueue) reject(reason string) {
+	if monitor.AdmissionRejectedCount != nil {
+		monitor.AdmissionRejectedCount.WithLabelValues(q.model, reason).Inc()
+	}
+}
+
+// admissionPriority is the priority configured for the user, which the session may lower
+func (c *Ctrl) admissionPriority(ctx *gin.Context, userAddress string) int {
+	priority := c.userPriorities[strings.ToLower(userAddress)]
+
+	// The session has been validated before admission
+	var token SessionToken
+	if err := json.Unmarshal([]byte(ctx.GetHeader("Session-Token")), &token); err == nil && token.Priority != nil {
+		if *token.Priority < priority {
+			priority = *token.Priority
+		}
+	}
+	return priority
+}
+
+// AcquireAdmission waits until the backend of the service can take the request. It is called
+// before the request is created, so that rejected requests leave no trace. The returned release
+// must be called once the request is done
+func (c *Ctrl) AcquireAdmission(ctx *gin.Context, svc config.Service, userAddress string) (func(), error) {
+	q, ok := c.admission[svc.ModelType]
+	if !ok {
+		return func() {}, nil
+	}
+	return q.acquire(ctx.Request.Context(), c.admissionPriority(ctx, userAddress))
+}
@@ -0,0 +1,250 @@
+package ctrl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	constant "github.com/0glabs/0g-serving-broker/inference/const"
+	"github.com/0glabs/0g-serving-broker/inference/internal/db"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// apiKeyGrantMaxAge bounds the age of a grant, which mints one key only, and of a revocation
+const apiKeyGrantMaxAge = 5 * time.Minute
+
+// APIKeyGrant is signed by a user to mint an API key bound to its account
+type APIKeyGrant struct {
+	Address  string `json:"address"`
+	Provider string `json:"provider"`
+	ChainID  int64  `json:"chainId"`
+	Contract string `json:"contract"`
+	// Timestamp and ExpiresAt are in milliseconds, like in SessionToken
+	Timestamp int64  `json:"timestamp"`
+	ExpiresAt int64  `json:"expiresAt"`
+	Name      string `json:"name"`
+	// SpendingCap bounds the fees billed to the key in neuron, empty means no cap
+	SpendingCap string `json:"spendingCap"`
+	// Models lists the models the key may call, empty means every model
+	Models []string `json:"models"`
+}
+
+// CreateAPIKeyRequest carries the grant as the JSON string that was signed
+type CreateAPIKeyRequest struct {
+	Grant     string `json:"grant" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+// APIKeyRevocation is signed by a user to revoke one of its API keys, e.g. a leaked key
+type APIKeyRevocation struct {
+	Address  string `json:"address"`
+	Provider string `json:"provider"`
+	ChainID  int64  `json:"chainId"`
+	Contract string `json:"contract"`
+	// Timestamp is in milliseconds, like in SessionToken
+	Timestamp int64  `json:"timestamp"`
+	ID        string `json:"id"`
+}
+
+// RevokeAPIKeyRequest carries the revocation as the JSON string that was signed
+type RevokeAPIKeyRequest struct {
+	Revocation string `json:"revocation" binding:"required"`
+	Signature  string `json:"signature" binding:"required"`
+}
+
+// CreatedAPIKey is returned once when a key is minted, the key cannot be read afterwards
+type CreatedAPIKey struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerAPIKey returns the broker API key of the request, if any. Other bearer tokens are left to
+// the backend
+func bearerAPIKey(ctx *gin.Context) string {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(token, constant.APIKeyPrefix) {
+		return ""
+	}
+	return token
+}
+
+// CreateAPIKey mints an API key from a grant signed by the user
+func (c *Ctrl) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (CreatedAPIKey, error) {
+	var grant APIKeyGrant
+	if err := json.Unmarshal([]byte(req.Grant), &grant); err != nil {
+		return CreatedAPIKey{}, errors.Wrap(err, "invalid API key grant format")
+	}
+	if !strings.EqualFold(grant.Provider, c.contract.ProviderAddress) {
+		return CreatedAPIKey{}, errors.New("API key grant is for different provider")
+	}
+	if err := c.checkDeployment(grant.ChainID, grant.Contract); err != nil {
+		return CreatedAPIKey{}, err
+	}
+	age := time.Since(time.UnixMilli(grant.Timestamp))
+	if age > apiKeyGrantMaxAge || age < -apiKeyGrantMaxAge {
+		return CreatedAPIKey{}, errors.New("API key grant expired")
+	}
+	expiresAt := time.UnixMilli(grant.ExpiresAt)
+	if !expiresAt.After(time.Now()) {
+		return CreatedAPIKey{}, errors.New("API key expiry is in the past")
+	}
+	var spendingCap *util.Amount
+	if grant.SpendingCap != "" {
+		parsed, err := util.ParseAmount(grant.SpendingCap)
+		if err != nil || parsed.Sign() < 0 {
+			return CreatedAPIKey{}, fmt.Errorf("invalid spending cap %s", grant.SpendingCap)
+		}
+		spendingCap = &parsed
+	}
+	for _, m := range grant.Models {
+		if !slices.ContainsFunc(c.Services, func(svc config.Service) bool { return svc.ModelType == m }) {
+			return CreatedAPIKey{}, fmt.Errorf("model %s is not served by this provider", m)
+		}
+	}
+	if err := c.verifyPersonalSignature(ctx, []byte(req.Grant), req.Signature, grant.Address); err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return CreatedAPIKey{}, errors.Wrap(err, "generate API key")
+	}
+	key := constant.APIKeyPrefix + hex.EncodeToString(secret)
+	revoked := false
+	apiKey := model.APIKey{
+		ID:          uuid.New().String(),
+		KeyHash:     hashAPIKey(key),
+		GrantHash:   crypto.Keccak256Hash([]byte(req.Grant)).Hex(),
+		User:        common.HexToAddress(grant.Address).Hex(),
+		Name:        grant.Name,
+		SpendingCap: spendingCap,
+		Spent:       util.NewAmount(0),
+		Models:      model.StringSlice(grant.Models),
+		ExpiresAt:   expiresAt,
+		Revoked:     &revoked,
+	}
+	if apiKey.Models == nil {
+		apiKey.Models = model.StringSlice{}
+	}
+	if err := c.db.CreateAPIKey(apiKey); err != nil {
+		// The grant hash is unique, so a replayed grant fails here
+		return CreatedAPIKey{}, errors.Wrap(err, "create API key, a grant mints one key only")
+	}
+	return CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// getAPIKey returns the key of a bearer token, it fails for unknown, revoked and expired keys
+func (c *Ctrl) getAPIKey(token string) (model.APIKey, error) {
+	key, err := c.db.GetAPIKeyByHash(hashAPIKey(token))
+	if err != nil {
+		if db.IgnoreNotFound(err) == nil {
+			return key, errors.New("invalid API key")
+		}
+		return key, errors.Wrap(err, "get API key")
+	}
+	if key.Revoked != nil && *key.Revoked {
+		return key, errors.New("API key revoked")
+	}
+	if time.Now().After(key.ExpiresAt) {
+		return key, errors.New("API key expired")
+	}
+	return key, nil
+}
+
+// validateAPIKey checks that the key of the request may call the service, and turns away early
+// the requests its spending cap cannot pay for. The cap is enforced when the balance of the
+// request is held
+func (c *Ctrl) validateAPIKey(ctx *gin.Context, svc config.Service, req model.Request, estimatedFee util.Amount) error {
+	key, err := c.getAPIKey(bearerAPIKey(ctx))
+	if err != nil {
+		return err
+	}
+	if len(key.Models) > 0 && !slices.Contains(key.Models, svc.ModelType) {
+		return fmt.Errorf("API key is not allowed to call model %s", svc.ModelType)
+	}
+
+	remaining, err := c.apiKeyRemainingSpend(req)
+	if err != nil {
+		return err
+	}
+	if remaining != nil && estimatedFee.Cmp(*remaining) > 0 {
+		return fmt.Errorf("API key spending cap of %s reached, %s left after the spending and the requests in flight", key.SpendingCap, *remaining)
+	}
+	return nil
+}
+
+// apiKeyRemainingSpend returns what the spending cap of the API key of a request leaves for it,
+// after the fees billed to the key and the holds of its other requests in flight. It is nil when
+// the request has no key or its key has no cap
+func (c *Ctrl) apiKeyRemainingSpend(req model.Request) (*util.Amount, error) {
+	if req.APIKeyID == "" {
+		return nil, nil
+	}
+	key, err := c.db.GetAPIKey(req.APIKeyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get API key")
+	}
+	if key.SpendingCap == nil {
+		return nil, nil
+	}
+	held, err := c.db.APIKeyHeld(req.APIKeyID, req.RequestHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "get API key holds")
+	}
+	remaining := key.SpendingCap.Sub(key.Spent).Sub(held)
+	return &remaining, nil
+}
+
+func (c *Ctrl) ListAPIKey(userAddress string) ([]model.APIKey, error) {
+	return c.db.ListAPIKey(common.HexToAddress(userAddress).Hex())
+}
+
+func (c *Ctrl) RevokeAPIKey(id string) error {
+	return c.db.RevokeAPIKey(model.APIKey{ID: id})
+}
+
+// RevokeUserAPIKey revokes a key of the user who signed the revocation, a key of another user is
+// not found
+func (c *Ctrl) RevokeUserAPIKey(ctx context.Context, req RevokeAPIKeyRequest) error {
+	var revocation APIKeyRevocation
+	if err := json.Unmarshal([]byte(req.Revocation), &revocation); err != nil {
+		return errors.Wrap(err, "invalid API key revocation format")
+	}
+	if !strings.EqualFold(revocation.Provider, c.contract.ProviderAddress) {
+		return errors.New("API key revocation is for different provider")
+	}
+	if err := c.checkDeployment(revocation.ChainID, revocation.Contract); err != nil {
+		return err
+	}
+	if revocation.ID == "" {
+		return errors.New("API key revocation has no id")
+	}
+	age := time.Since(time.UnixMilli(revocation.Timestamp))
+	if age > apiKeyGrantMaxAge || age < -apiKeyGrantMaxAge {
+		return errors.New("API key revocation expired")
+	}
+	if err := c.verifyPersonalSignature(ctx, []byte(req.Revocation), req.Signature, revocation.Address); err != nil {
+		return err
+	}
+
+	return c.db.RevokeAPIKey(model.APIKey{ID: revocation.ID, User: common.HexToAddress(revocation.Address).Hex()})
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"compress/flate"
 	"compress/gzip"
@@ -20,10 +21,12 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/gin-gonic/gin"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
 	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 )
 
@@ -32,22 +35,46 @@ const ChatPrefix = "chat"
 type SigningAlgo int
 
 const (
+	// ECDSA signs the personal message "requestSha256:responseSha256:requestHash:promptTokens:completionTokens:fee"
 	ECDSA SigningAlgo = iota
+	// EIP712 signs the ChatResponse typed data, verifiable by contracts and wallets
+	EIP712
 )
 
 func (r SigningAlgo) String() string {
-	return [...]string{"ecdsa"}[r]
+	return [...]string{"ecdsa", "eip712"}[r]
+}
+
+func ParseSigningAlgo(s string) (SigningAlgo, error) {
+	switch strings.ToLower(s) {
+	case "", "ecdsa":
+		return ECDSA, nil
+	case "eip712":
+		return EIP712, nil
+	default:
+		return ECDSA, fmt.Errorf("signing algorithm %s is not supported", s)
+	}
 }
 
 type ChatSignature struct {
+	// Text is what was signed, the typed data JSON for EIP712
 	Text                string         `json:"text"`
 	SignatureEcdsa      string         `json:"signature"`
 	SigningAddressEcdsa common.Address `json:"signing_address"`
 	SigningAlgo         string         `json:"signing_algo"`
+	// Domain is the EIP-712 domain, set only for EIP712 signatures
+	Domain *apitypes.TypedDataDomain `json:"domain,omitempty"`
+	// What the signature commits to besides the request and response hashes, it matches the
+	// request row settled by the provider
+	RequestHash      string `json:"request_hash"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Fee              string `json:"fee"`
 }
 
 type RequestBody struct {
-	Messages []Message `json:"messages"`
+	Messages []Message         `json:"messages"`
+	Tools    []json.RawMessage `json:"tools,omitempty"`
 }
 
 type CompletionChunk struct {
@@ -67,67 +94,169 @@ type Usage struct {
 
 type Choice struct {
 	Message Message `json:"message"`
-	Delta   struct {
-		Content string `json:"content"`
-	} `json:"delta"`
+	// Delta carries the same fields as Message, each chunk holding a fragment of them
+	Delta Message `json:"delta"`
+	// Text is set by the legacy completions route
+	Text         string `json:"text"`
 	FinishReason string `json:"finish_reason"`
 }
 
+// billableText is the output text of a choice, whichever of the fields the route fills
+func (c Choice) billableText() string {
+	return c.Message.billableText() + c.Delta.billableText() + c.Text
+}
+
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string         `json:"role"`
+	Content MessageContent `json:"content"`
+	Name    string         `json:"name,omitempty"`
+	// Refusal is set instead of Content when the model declines to answer
+	Refusal string `json:"refusal,omitempty"`
+	// ReasoningContent is the chain of thought returned by reasoning models
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID       string     `json:"tool_call_id,omitempty"`
+	// FunctionCall is the deprecated predecessor of ToolCalls
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
 }
 
-// GetChatbotInputFeeAndCount returns both the input fee and count for efficient request creation
-// Note: This returns an ESTIMATE based on message byte size for validation purposes
-// The actual token count will be obtained from the LLM response
-func (c *Ctrl) GetChatbotInputFeeAndCount(reqBody []byte) (string, int64, error) {
-	inputCount, err := getInputCount(reqBody)
-	if err != nil {
-		return "", 0, errors.Wrap(err, "get input count")
+// billableText concatenates every part of the message that the model reads or generates as text
+func (m Message) billableText() string {
+	var b strings.Builder
+	b.WriteString(m.Content.Text())
+	b.WriteString(m.Refusal)
+	b.WriteString(m.ReasoningContent)
+	for _, call := range m.ToolCalls {
+		b.WriteString(call.Function.Name)
+		b.WriteString(call.Function.Arguments)
+	}
+	if m.FunctionCall != nil {
+		b.WriteString(m.FunctionCall.Name)
+		b.WriteString(m.FunctionCall.Arguments)
+	}
+	return b.String()
+}
+
+type ToolCall struct {
+	// Index identifies the call that a stream delta belongs to
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name string `json:"name,omitempty"`
+	// Arguments is JSON encoded by the model, streamed in fragments
+	Arguments string `json:"arguments,omitempty"`
+}
+
+const (
+	ContentPartText       = "text"
+	ContentPartImageURL   = "image_url"
+	ContentPartInputAudio = "input_audio"
+)
+
+type ContentPart struct {
+	Type       string          `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	ImageURL   *ImageURL       `json:"image_url,omitempty"`
+	InputAudio json.RawMessage `json:"input_audio,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// MessageContent is either a plain string or a list of content parts, a string is held as a
+// single text part
+type MessageContent []ContentPart
+
+func (c *MessageContent) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*c = nil
+		return nil
 	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = MessageContent{{Type: ContentPartText, Text: text}}
+		return nil
+	}
+	var parts []ContentPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.Wrap(err, "content is neither a string nor a list of parts")
+	}
+	*c = parts
+	return nil
+}
 
-	expectedInputFee, err := util.Multiply(inputCount, c.Service.InputPrice)
-	if err != nil {
-		return "", 0, errors.Wrap(err, "calculate input fee")
+func (c MessageContent) MarshalJSON() ([]byte, error) {
+	if c == nil {
+		return []byte("null"), nil
+	}
+	if len(c) == 1 && c[0].Type == ContentPartText {
+		return json.Marshal(c[0].Text)
 	}
-	return expectedInputFee.String(), inputCount, nil
+	return json.Marshal([]ContentPart(c))
 }
 
-// getInputCount provides an estimation of input tokens based on message byte size
-// This is used ONLY for initial balance validation before the request is sent to LLM
-// The actual token count from LLM response will replace this estimate
-func getInputCount(reqBody []byte) (int64, error) {
-	var bodyMap map[string]interface{}
-	if err := json.Unmarshal(reqBody, &bodyMap); err != nil {
-		return 0, fmt.Errorf("failed to unmarshal reqBody: %w", err)
+// Text concatenates the text parts
+func (c MessageContent) Text() string {
+	var b strings.Builder
+	for _, part := range c {
+		if part.Type == ContentPartText {
+			b.WriteString(part.Text)
+		}
 	}
-	messages, ok := bodyMap["messages"]
-	if !ok {
-		return 0, fmt.Errorf("messages field not found in reqBody")
+	return b.String()
+}
+
+// Images returns the number of image parts
+func (c MessageContent) Images() int {
+	n := 0
+	for _, part := range c {
+		if part.Type == ContentPartImageURL {
+			n++
+		}
 	}
-	messagesBytes, err := json.Marshal(messages)
+	return n
+}
+
+// GetInputFeeAndCount returns both the input fee and count for efficient request creation
+// Note: This is counted with the service tokenizer, or ESTIMATED from the body size when there is none,
+// for validation purposes. The actual token count will be obtained from the LLM response
+func (c *Ctrl) GetInputFeeAndCount(svc config.Service, route string, reqBody []byte) (util.Amount, int64, error) {
+	handler, err := getRouteHandler(route)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal messages: %w", err)
+		return util.Amount{}, 0, err
 	}
-	// Estimation based on message byte length for validation only
-	return int64(len(messagesBytes)), nil
+	inputCount, err := handler.inputCount(reqBody, c.tokenizers[svc.ModelType])
+	if err != nil {
+		return util.Amount{}, 0, errors.Wrap(err, "get input count")
+	}
+	return util.TokenFee(svc.InputPrice, inputCount, svc.PriceUnit), inputCount, nil
 }
 
-func (c *Ctrl) handleChatbotResponse(ctx *gin.Context, resp *http.Response, account model.User, outputPrice int64, reqBody []byte, reqModel model.Request) error {
+func (c *Ctrl) handleChatbotResponse(ctx *gin.Context, resp *http.Response, account model.User, svc config.Service, reqBody []byte, reqModel model.Request, route string) error {
+	handler, err := getRouteHandler(route)
+	if err != nil {
+		c.handleBrokerError(ctx, err, "get route handler")
+		return err
+	}
 	isStream, err := isStream(reqBody)
 	if err != nil {
 		c.handleBrokerError(ctx, err, "check if stream")
 		return err
 	}
 	if !isStream {
-		return c.handleChargingResponse(ctx, resp, account, outputPrice, reqBody, reqModel)
+		return c.handleChargingResponse(ctx, resp, account, svc, reqBody, reqModel, handler)
 	} else {
-		return c.handleChargingStreamResponse(ctx, resp, account, outputPrice, reqBody, reqModel)
+		return c.handleChargingStreamResponse(ctx, resp, account, svc, reqBody, reqModel, handler)
 	}
 }
 
-func (c *Ctrl) handleChargingResponse(ctx *gin.Context, resp *http.Response, account model.User, outputPrice int64, reqBody []byte, reqModel model.Request) error {
+func (c *Ctrl) handleChargingResponse(ctx *gin.Context, resp *http.Response, account model.User, svc config.Service, reqBody []byte, reqModel model.Request, handler *routeHandler) error {
 	defer resp.Body.Close()
 
 	var rawBody bytes.Buffer
@@ -139,7 +268,7 @@ func (c *Ctrl) handleChargingResponse(ctx *gin.Context, resp *http.Response, acc
 		return err
 	}
 
-	if err := c.decodeAndProcess(ctx, rawBody.Bytes(), resp.Header.Get("Content-Encoding"), account, outputPrice, false, reqBody, reqModel, rawBody.Bytes()); err != nil {
+	if err := c.decodeAndProcess(ctx, rawBody.Bytes(), resp.Header.Get("Content-Encoding"), account, svc, reqBody, reqModel, handler); err != nil {
 		c.logger.Errorf("decode and process failed: %v", err)
 		return err
 	}
@@ -147,54 +276,68 @@ func (c *Ctrl) handleChargingResponse(ctx *gin.Context, resp *http.Response, acc
 	return nil
 }
 
-func (c *Ctrl) handleChargingStreamResponse(ctx *gin.Context, resp *http.Response, account model.User, outputPrice int64, reqBody []byte, reqModel model.Request) error {
+// handleChargingStreamResponse forwards the stream chunk by chunk and decodes each event as it
+// passes through, so neither the raw nor the decoded response is held in memory
+func (c *Ctrl) handleChargingStreamResponse(ctx *gin.Context, resp *http.Response, account model.User, svc config.Service, reqBody []byte, reqModel model.Request, handler *routeHandler) error {
 	defer resp.Body.Close()
 
-	var rawBody bytes.Buffer
-
-	var streamErr error = nil
-	var responseChunk []byte = nil
-	ctx.Stream(func(w io.Writer) bool {
-		reader := bufio.NewReader(io.TeeReader(resp.Body, &rawBody))
+	// The signed response hash covers the raw bytes sent to the client
+	respHash := sha256.New()
+	upstream := &passThroughReader{
+		src:   resp.Body,
+		dst:   io.MultiWriter(ctx.Writer, respHash),
+		flush: ctx.Writer.Flush,
+	}
+	parser := newSSEParser(initializeReader(upstream, resp.Header.Get("Content-Encoding")))
 
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					return false
+	var tracker streamTracker
+	for {
+		event, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if upstream.writeErr != nil || ctx.Request.Context().Err() != nil {
+				// The client went away, the upstream request is cancelled when we return
+				// and the output already streamed to the client is billed
+				c.logger.Warnf("client disconnected mid-stream, billing request %s as truncated: %v", reqModel.RequestHash, err)
+				if _, _, err := c.finalizeResponse(ctx, handler, tracker.usage, tracker.output.String(), svc, reqBody, reqModel, true); err != nil {
+					c.logger.Errorf("bill truncated stream failed: %v", err)
 				}
-				c.handleBrokerError(ctx, err, "read from body")
-				streamErr = err
-				return false
-			}
-
-			if responseChunk == nil {
-				responseChunk = []byte(strings.TrimSpace(strings.TrimPrefix(line, "data: ")))
-			}
-
-			_, streamErr = w.Write([]byte(line))
-			if streamErr != nil {
-				c.handleBrokerError(ctx, err, "write to stream")
-				return false
+				return err
 			}
-
-			ctx.Writer.Flush()
+			c.handleBrokerError(ctx, err, "read from body")
+			return err
+		}
+		if err := tracker.track(handler, event); err != nil {
+			c.handleBrokerError(ctx, err, "decode stream event")
+			return err
 		}
-	})
+	}
 
-	if streamErr != nil {
-		return streamErr
+	// Forward anything the decoder left unread, e.g. a compression trailer
+	if _, err := io.Copy(io.Discard, upstream); err != nil {
+		c.handleBrokerError(ctx, err, "read from body")
+		return err
 	}
 
-	// Fully read and then start decoding and processing
-	if err := c.decodeAndProcess(ctx, rawBody.Bytes(), resp.Header.Get("Content-Encoding"), account, outputPrice, true, reqBody, reqModel, responseChunk); err != nil {
+	usage, fee, err := c.finalizeResponse(ctx, handler, tracker.usage, tracker.output.String(), svc, reqBody, reqModel, false)
+	if err != nil {
 		c.handleBrokerError(ctx, err, "decode and process")
 		return err
 	}
 
+	if !reqModel.VLLMProxy {
+		if err := c.signChat(reqBody, hex.EncodeToString(respHash.Sum(nil)), tracker.chatID, reqModel, svc, usage, fee); err != nil {
+			c.handleBrokerError(ctx, err, "sign chat")
+			return err
+		}
+	}
+
 	return nil
 }
-func (c *Ctrl) decodeAndProcess(ctx context.Context, data []byte, encodingType string, account model.User, outputPrice int64, isStream bool, reqBody []byte, reqModel model.Request, respChunk []byte) error {
+
+func (c *Ctrl) decodeAndProcess(ctx context.Context, data []byte, encodingType string, account model.User, svc config.Service, reqBody []byte, reqModel model.Request, handler *routeHandler) error {
 	// Decode the raw data
 	decodeReader := initializeReader(bytes.NewReader(data), encodingType)
 	decodedBody, err := io.ReadAll(decodeReader)
@@ -202,47 +345,24 @@ func (c *Ctrl) decodeAndProcess(ctx context.Context, data []byte, encodingType s
 		return errors.Wrap(err, "Error decoding body")
 	}
 
-	var output string
-	var usage *Usage
-
-	if !isStream {
-		if err := c.processSingleResponse(ctx, decodedBody, outputPrice, &output, reqModel.RequestHash, &usage); err != nil {
-			return err
-		}
-	} else {
-		// Parse and decode data line by line for streams
-		lines := bytes.Split(decodedBody, []byte("\n"))
-
-		for _, line := range lines {
-			if isStreamDone(line) {
-				// For stream responses, usage info comes before [DONE]
-				if usage != nil {
-					return c.finalizeResponseWithUsage(ctx, usage, outputPrice, reqModel.RequestHash, c.Service.InputPrice)
-				}
-				return c.finalizeResponse(ctx, output, outputPrice, reqModel.RequestHash)
-			}
-
-			// Skip empty lines
-			if isLineEmpty(line) {
-				continue
-			}
-
-			// Check if this line contains usage information
-			if extractedUsage := c.extractUsageFromLine(line); extractedUsage != nil {
-				usage = extractedUsage
-				continue
-			}
-
-			chunkOutput, err := c.processLine(line)
-			if err != nil {
-				return err
-			}
-			output += chunkOutput
-		}
+	// For non-stream responses, usage info is in the same response
+	payload := bytes.TrimPrefix(decodedBody, []byte("data: "))
+	usage, output, err := handler.extractUsage(payload)
+	if err != nil {
+		return err
+	}
+	usage, fee, err := c.finalizeResponse(ctx, handler, usage, output, svc, reqBody, reqModel, false)
+	if err != nil {
+		return err
 	}
 
 	if !reqModel.VLLMProxy {
-		if err := c.signChat(reqBody, data, respChunk); err != nil {
+		var chatResp CompletionChunk
+		if err := json.Unmarshal(payload, &chatResp); err != nil {
+			return errors.Wrap(err, "Chat id could not be extracted from the response")
+		}
+		respSha256 := sha256.Sum256(data)
+		if err := c.signChat(reqBody, hex.EncodeToString(respSha256[:]), chatResp.ID, reqModel, svc, usage, fee); err != nil {
 			return err
 		}
 	}
@@ -250,24 +370,50 @@ func (c *Ctrl) decodeAndProcess(ctx context.Context, data []byte, encodingType s
 	return nil
 }
 
-func (c *Ctrl) signChat(reqBody, respData, respChunk []byte) error {
-	hashAndEncode := func(b []byte) string {
-		h := sha256.Sum256(b)
-		return hex.EncodeToString(h[:])
+// signChat signs the response with the configured algorithm. The signature also commits to the
+// billed usage, the fee and the request hash, so that users can prove a billing discrepancy
+// against the request the provider settles
+func (c *Ctrl) signChat(reqBody []byte, responseSha256, chatID string, reqModel model.Request, svc config.Service, usage *Usage, fee util.Amount) error {
+	if chatID == "" {
+		// Routes such as image generation carry no id to look the signature up by
+		return nil
 	}
 
-	requestSha256 := hashAndEncode(reqBody)
-	responseSha256 := hashAndEncode(respData)
-
-	var chatResp CompletionChunk
-	err := json.Unmarshal(respChunk, &chatResp)
-	if err != nil {
-		return errors.Wrap(err, "Chat id could not be extracted from the response")
+	reqSha256 := sha256.Sum256(reqBody)
+	requestSha256 := hex.EncodeToString(reqSha256[:])
+
+	var text string
+	var hash []byte
+	var domain *apitypes.TypedDataDomain
+	switch c.signingAlgo {
+	case EIP712:
+		attestation := responseAttestation{
+			Provider:       c.contract.ProviderAddress,
+			User:           reqModel.UserAddress,
+			Model:          svc.ModelType,
+			RequestSha256:  requestSha256,
+			ResponseSha256: responseSha256,
+			RequestHash:    reqModel.RequestHash,
+			InputTokens:    int64(usage.PromptTokens),
+			OutputTokens:   int64(usage.CompletionTokens),
+			Fee:            fee.Big(),
+			Timestamp:      time.Now().Unix(),
+		}
+		var err error
+		hash, text, err = c.hashTypedData(attestation)
+		if err != nil {
+			return err
+		}
+		d := c.eip712Domain()
+		domain = &d
+	default:
+		// Older verifiers only read the first two fields
+		text = fmt.Sprintf("%s:%s:%s:%d:%d:%s", requestSha256, responseSha256, reqModel.RequestHash,
+			usage.PromptTokens, usage.CompletionTokens, fee.String())
+		hash = accounts.TextHash([]byte(text))
 	}
-	chatID := chatResp.ID
 
-	text := fmt.Sprintf("%s:%s", requestSha256, responseSha256)
-	sig, err := crypto.Sign(accounts.TextHash([]byte(text)), c.teeService.ProviderSigner)
+	sig, err := crypto.Sign(hash, c.teeService.ProviderSigner)
 	if err != nil {
 		return err
 	}
@@ -280,7 +426,26 @@ func (c *Ctrl) signChat(reqBody, respData, respChunk []byte) error {
 		Text:                text,
 		SignatureEcdsa:      hexutil.Encode(sig),
 		SigningAddressEcdsa: c.teeService.Address,
-		SigningAlgo:         ECDSA.String(),
+		SigningAlgo:         c.signingAlgo.String(),
+		Domain:              domain,
+		RequestHash:         reqModel.RequestHash,
+		PromptTokens:        int64(usage.PromptTokens),
+		CompletionTokens:    int64(usage.CompletionTokens),
+		Fee:                 fee.String(),
+	}
+
+	if err := c.signatures.PutSignature(model.ChatSignature{
+		ChatID:         chatID,
+		RequestHash:    reqModel.RequestHash,
+		Text:           chatSignature.Text,
+		Signature:      chatSignature.SignatureEcdsa,
+		SigningAddress: chatSignature.SigningAddressEcdsa.Hex(),
+		SigningAlgo:    chatSignature.SigningAlgo,
+		InputCount:     chatSignature.PromptTokens,
+		OutputCount:    chatSignature.CompletionTokens,
+		Fee:            chatSignature.Fee,
+	}); err != nil {
+		return errors.Wrap(err, "store chat signature")
 	}
 
 	key := c.chatCacheKey(chatID)
@@ -293,123 +458,65 @@ func (*Ctrl) chatCacheKey(chatID string) string {
 	return fmt.Sprintf("%s:%s", ChatPrefix, chatID)
 }
 
-func (c *Ctrl) processSingleResponse(ctx context.Context, decodedBody []byte, outputPrice int64, output *string, requestHash string, usage **Usage) error {
-	line := bytes.TrimPrefix(decodedBody, []byte("data: "))
-	var chunk CompletionChunk
-	if err := json.Unmarshal(line, &chunk); err != nil {
-		return errors.Wrap(err, "Error unmarshaling JSON")
-	}
-
-	for _, choice := range chunk.Choices {
-		*output += choice.Message.Content
-	}
-	
-	// For non-stream responses, usage info is in the same response
-	if chunk.Usage != nil {
-		*usage = chunk.Usage
-		return c.updateAccountWithUsage(ctx, chunk.Usage, outputPrice, requestHash, c.Service.InputPrice)
-	}
-	
-	// Fallback to old logic if no usage info
-	return c.updateAccountWithOutput(ctx, *output, outputPrice, requestHash)
-}
-
-func (c *Ctrl) processLine(line []byte) (string, error) {
-	line = bytes.TrimPrefix(line, []byte("data: "))
-	var chunk CompletionChunk
-	if err := json.Unmarshal(line, &chunk); err != nil {
-		return "", errors.Wrap(err, "Error unmarshaling JSON")
-	}
-
-	var outputChunk string
-	for _, choice := range chunk.Choices {
-		outputChunk += choice.Delta.Content
-	}
-	return outputChunk, nil
-}
-
-func (c *Ctrl) finalizeResponse(ctx context.Context, output string, outputPrice int64, requestHash string) error {
-	return c.updateAccountWithOutput(ctx, output, outputPrice, requestHash)
-}
-
-// extractUsageFromLine extracts usage information from a stream response line
-func (c *Ctrl) extractUsageFromLine(line []byte) *Usage {
-	line = bytes.TrimPrefix(line, []byte("data: "))
-	var chunk CompletionChunk
-	if err := json.Unmarshal(line, &chunk); err != nil {
-		return nil
+// finalizeResponse updates the account with accurate token counts from LLM when usage is reported,
+// and falls back to counting the tokens of the prompt and the output otherwise
+// truncated marks a stream cut short by the client, only what was streamed so far is billed
+// The billed usage and total fee are returned
+func (c *Ctrl) finalizeResponse(ctx context.Context, handler *routeHandler, usage *Usage, output string, svc config.Service, reqBody []byte, reqModel model.Request, truncated bool) (*Usage, util.Amount, error) {
+	usageSource := model.UsageSourceReported
+	if usage == nil {
+		var err error
+		usage, usageSource, err = c.fallbackUsage(handler, output, svc, reqBody)
+		if err != nil {
+			return nil, util.Amount{}, err
+		}
 	}
-	return chunk.Usage
+	c.chargeTokens(reqModel.UserAddress, usage)
+	fee, err := c.updateAccountWithUsage(ctx, handler, usage, reqModel, usageSource, truncated)
+	return usage, fee, err
 }
 
-// finalizeResponseWithUsage updates the account with accurate token counts from LLM
-func (c *Ctrl) finalizeResponseWithUsage(ctx context.Context, usage *Usage, outputPrice int64, requestHash string, inputPrice int64) error {
-	return c.updateAccountWithUsage(ctx, usage, outputPrice, requestHash, inputPrice)
-}
-
-// updateAccountWithUsage updates the request with accurate token counts from the LLM response
-func (c *Ctrl) updateAccountWithUsage(_ context.Context, usage *Usage, outputPrice int64, requestHash string, inputPrice int64) error {
+// updateAccountWithUsage updates the request with accurate token counts from the LLM response,
+// billed at the prices snapshotted on the request
+func (c *Ctrl) updateAccountWithUsage(_ context.Context, handler *routeHandler, usage *Usage, reqModel model.Request, usageSource string, truncated bool) (util.Amount, error) {
 	// Calculate actual fees based on LLM-provided token counts
-	inputFee, err := util.Multiply(inputPrice, int64(usage.PromptTokens))
-	if err != nil {
-		return errors.Wrap(err, "Error calculating input fee from actual tokens")
-	}
-	
-	outputFee, err := util.Multiply(outputPrice, int64(usage.CompletionTokens))
-	if err != nil {
-		return errors.Wrap(err, "Error calculating output fee from actual tokens")
-	}
-	
-	totalFee, err := util.Add(inputFee, outputFee)
-	if err != nil {
-		return errors.Wrap(err, "Error calculating total fee")
-	}
-	
+	inputFee, outputFee := handler.fee(usage, reqModel.InputPrice, reqModel.OutputPrice, reqModel.PriceUnit)
+	totalFee := inputFee.Add(outputFee)
+
 	// Update the request with accurate token counts and fees
-	if err := c.db.UpdateRequestWithAccurateTokens(requestHash, inputFee.String(), outputFee.String(), totalFee.String(), 
-		int64(usage.PromptTokens), int64(usage.CompletionTokens)); err != nil {
-		return errors.Wrap(err, "Error updating request with accurate tokens")
+	if err := c.db.UpdateRequestWithAccurateTokens(reqModel.RequestHash, inputFee, outputFee, totalFee,
+		int64(usage.PromptTokens), int64(usage.CompletionTokens), usageSource, truncated); err != nil {
+		return util.Amount{}, errors.Wrap(err, "Error updating request with accurate tokens")
 	}
-	
-	return nil
-}
 
-// updateAccountWithOutput is the FALLBACK method when LLM doesn't provide usage information
-// It estimates tokens by counting space-separated words (inaccurate but better than nothing)
-// This should only be used when the LLM response doesn't include usage data
-func (c *Ctrl) updateAccountWithOutput(_ context.Context, output string, outputPrice int64, requestHash string) error {
-	// WARNING: This is a rough estimation based on word count, not actual tokens
-	outputCount := int64(len(strings.Fields(output)))
-	lastResponseFee, err := util.Multiply(outputPrice, outputCount)
-	if err != nil {
-		return errors.Wrap(err, "Error calculating last response fee")
-	}
+	return totalFee, nil
+}
 
-	request, err := c.db.GetRequest(requestHash)
-	if err != nil {
-		return errors.Wrap(err, "Error fetching request")
+// fallbackUsage is the FALLBACK used when LLM doesn't provide usage information.
+// It runs the service tokenizer over the prompt and the accumulated output. Without a tokenizer,
+// it estimates output tokens by counting space-separated words and bills no input, which is
+// inaccurate but better than nothing
+func (c *Ctrl) fallbackUsage(handler *routeHandler, output string, svc config.Service, reqBody []byte) (*Usage, string, error) {
+	tk, ok := c.tokenizers[svc.ModelType]
+	if !ok {
+		// WARNING: This is a rough estimation based on word count, not actual tokens
+		outputCount := len(strings.Fields(output))
+		return &Usage{
+			CompletionTokens: outputCount,
+			TotalTokens:      outputCount,
+		}, model.UsageSourceEstimated, nil
 	}
 
-	fee, err := util.Add(lastResponseFee, request.InputFee)
+	inputCount, err := handler.inputCount(reqBody, tk)
 	if err != nil {
-		return err
-	}
-
-	// Update the request's output fee, total fee, and output count
-	// No longer update unsettled fee in user table to avoid concurrency issues
-	if err := c.db.UpdateRequestFeesAndCount(requestHash, lastResponseFee.String(), fee.String(), outputCount); err != nil {
-		return errors.Wrap(err, "Error updating request fees and count")
-	}
-
-	return nil
-}
-
-func isStreamDone(line []byte) bool {
-	return bytes.Equal(line, []byte("data: [DONE]"))
-}
-
-func isLineEmpty(line []byte) bool {
-	return bytes.Equal(line, []byte(""))
+		return nil, "", errors.Wrap(err, "Error counting input tokens")
+	}
+	outputCount := tk.CountTokens(output)
+	return &Usage{
+		PromptTokens:     int(inputCount),
+		CompletionTokens: outputCount,
+		TotalTokens:      int(inputCount) + outputCount,
+	}, model.UsageSourceTokenized, nil
 }
 
 func isStream(body []byte) (bool, error) {
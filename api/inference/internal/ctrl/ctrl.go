@@ -1,16 +1,19 @@
 package ctrl
 
 import (
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 
+	"github.com/0glabs/0g-serving-broker/common/errors"
 	"github.com/0glabs/0g-serving-broker/common/log"
 	"github.com/0glabs/0g-serving-broker/common/tee"
 	"github.com/0glabs/0g-serving-broker/inference/config"
 	providercontract "github.com/0glabs/0g-serving-broker/inference/internal/contract"
 	"github.com/0glabs/0g-serving-broker/inference/internal/db"
+	"github.com/0glabs/0g-serving-broker/inference/internal/tokenizer"
 )
 
 type Ctrl struct {
@@ -22,13 +25,33 @@ type Ctrl struct {
 
 	autoSettleBufferTime time.Duration
 
-	Service config.Service
+	// Services[0] is the primary service, used when a request names no model
+	Services []config.Service
+	// tokenizers are keyed by the service model type
+	tokenizers map[string]tokenizer.Tokenizer
+	// upstreams are the pooled backend clients, keyed by the service model type
+	upstreams   map[string]*upstreamClient
+	rateLimiter *rateLimiter
+	// admission holds the queue of every service with a bounded number of requests in flight
+	admission      map[string]*admissionQueue
+	userPriorities map[string]int
+	adminAuth      *adminAuth
 
-	teeService          *tee.TeeService
+	teeService *tee.TeeService
+	// signatures persists response signatures, svcCache keeps the recent ones for chatCacheExpiration
+	signatures          SignatureStore
+	signingAlgo         SigningAlgo
 	chatCacheExpiration time.Duration
-	
+	signatureRetention  time.Duration
+	// usageRetention is how long the usage records of settled requests are kept
+	usageRetention time.Duration
+
 	// Session validation cache
-	sessionCache *cache.Cache
+	sessionCache       *cache.Cache
+	sessionMaxLifetime time.Duration
+	// siweDomain and siweCredentialLifetime configure the SIWE login
+	siweDomain             string
+	siweCredentialLifetime time.Duration
 }
 
 func New(
@@ -38,19 +61,74 @@ func New(
 	svcCache *cache.Cache,
 	teeService *tee.TeeService,
 	logger log.Logger,
-) *Ctrl {
+) (*Ctrl, error) {
+	tokenizers := make(map[string]tokenizer.Tokenizer)
+	for _, svc := range cfg.Services {
+		if svc.Tokenizer == "" {
+			continue
+		}
+		tk, err := tokenizer.Load(svc.Tokenizer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load tokenizer of model %s", svc.ModelType)
+		}
+		tokenizers[svc.ModelType] = tk
+	}
+
+	upstreams := make(map[string]*upstreamClient, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		u, err := newUpstreamClient(svc)
+		if err != nil {
+			return nil, err
+		}
+		upstreams[svc.ModelType] = u
+	}
+
+	admission := make(map[string]*admissionQueue)
+	if cfg.Admission.MaxInFlight > 0 {
+		for _, svc := range cfg.Services {
+			admission[svc.ModelType] = newAdmissionQueue(svc.ModelType, cfg.Admission)
+		}
+	}
+	userPriorities := make(map[string]int, len(cfg.Admission.UserPriorities))
+	for user, priority := range cfg.Admission.UserPriorities {
+		userPriorities[strings.ToLower(user)] = priority
+	}
+
+	adminAuth, err := newAdminAuth(cfg.AdminAuth, contract.ProviderAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	signingAlgo, err := ParseSigningAlgo(cfg.SigningAlgo)
+	if err != nil {
+		return nil, err
+	}
+
 	p := &Ctrl{
 		autoSettleBufferTime: time.Duration(cfg.Interval.AutoSettleBufferTime) * time.Second,
 		db:                   db,
 		contract:             contract,
-		Service:              cfg.Service,
+		Services:             cfg.Services,
+		tokenizers:           tokenizers,
+		upstreams:            upstreams,
+		rateLimiter:          newRateLimiter(cfg.RateLimit),
+		admission:            admission,
+		userPriorities:       userPriorities,
+		adminAuth:            adminAuth,
 		svcCache:             svcCache,
 		teeService:           teeService,
+		signatures:           db,
+		signingAlgo:          signingAlgo,
 		chatCacheExpiration:  cfg.ChatCacheExpiration,
+		signatureRetention:   cfg.SignatureRetention,
+		usageRetention:       cfg.UsageRetention,
 		logger:               logger,
 		// Initialize session cache with 5 minute expiration and cleanup every 10 minutes
-		sessionCache:         cache.New(5*time.Minute, 10*time.Minute),
+		sessionCache:           cache.New(5*time.Minute, 10*time.Minute),
+		sessionMaxLifetime:     cfg.SessionMaxLifetime,
+		siweDomain:             siweDomainOf(cfg),
+		siweCredentialLifetime: durationOrDefault(cfg.SIWE.CredentialLifetime, time.Hour),
 	}
 
-	return p
+	return p, nil
 }
@@ -0,0 +1,113 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+)
+
+const (
+	eip712DomainName    = "0G Serving Broker"
+	eip712DomainVersion = "1"
+	eip712PrimaryType   = "ChatResponse"
+)
+
+var eip712Types = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	eip712PrimaryType: {
+		{Name: "provider", Type: "address"},
+		{Name: "user", Type: "address"},
+		{Name: "model", Type: "string"},
+		{Name: "requestSha256", Type: "bytes32"},
+		{Name: "responseSha256", Type: "bytes32"},
+		{Name: "requestHash", Type: "string"},
+		{Name: "inputTokens", Type: "uint256"},
+		{Name: "outputTokens", Type: "uint256"},
+		{Name: "fee", Type: "uint256"},
+		{Name: "timestamp", Type: "uint256"},
+	},
+}
+
+// responseAttestation is the structured content signed for a response
+type responseAttestation struct {
+	Provider       string
+	User           string
+	Model          string
+	RequestSha256  string
+	ResponseSha256 string
+	// RequestHash identifies the request row that is settled
+	RequestHash  string
+	InputTokens  int64
+	OutputTokens int64
+	Fee          *big.Int
+	Timestamp    int64
+}
+
+// eip712Domain binds signatures to the serving contract of this broker, so that they can be
+// verified on-chain with ecrecover
+func (c *Ctrl) eip712Domain() apitypes.TypedDataDomain {
+	chainID := big.NewInt(0)
+	if c.contract.ChainID != nil {
+		chainID = c.contract.ChainID
+	}
+	return apitypes.TypedDataDomain{
+		Name:              eip712DomainName,
+		Version:           eip712DomainVersion,
+		ChainId:           (*math.HexOrDecimal256)(chainID),
+		VerifyingContract: c.contract.ContractAddress.Hex(),
+	}
+}
+
+func (c *Ctrl) typedData(a responseAttestation) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types:       eip712Types,
+		PrimaryType: eip712PrimaryType,
+		Domain:      c.eip712Domain(),
+		Message: apitypes.TypedDataMessage{
+			"provider":       a.Provider,
+			"user":           a.User,
+			"model":          a.Model,
+			"requestSha256":  "0x" + a.RequestSha256,
+			"responseSha256": "0x" + a.ResponseSha256,
+			"requestHash":    a.RequestHash,
+			"inputTokens":    strconv.FormatInt(a.InputTokens, 10),
+			"outputTokens":   strconv.FormatInt(a.OutputTokens, 10),
+			"fee":            a.Fee.String(),
+			"timestamp":      strconv.FormatInt(a.Timestamp, 10),
+		},
+	}
+}
+
+// hashTypedData returns the EIP-712 digest of the attestation together with the typed data
+// JSON that is returned to users as the signed text
+func (c *Ctrl) hashTypedData(a responseAttestation) ([]byte, string, error) {
+	typedData := c.typedData(a)
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "hash typed data")
+	}
+	text, err := json.Marshal(typedData)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "marshal typed data")
+	}
+	return hash, string(text), nil
+}
+
+// typedDataDomain recovers the domain from the typed data JSON kept as the signed text
+func typedDataDomain(text string) (*apitypes.TypedDataDomain, error) {
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal([]byte(text), &typedData); err != nil {
+		return nil, errors.Wrap(err, "unmarshal typed data")
+	}
+	return &typedData.Domain, nil
+}
@@ -0,0 +1,150 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// ClampMaxTokens caps the output tokens of a request to what its user pays for: the balance, for
+// the services with ClampMaxTokens, and the spending cap of its session or API key when it has
+// one. The balance available is the locked balance minus the unsettled fees, the holds of the
+// other requests in flight and the input fee of the request, and a cap likewise leaves the fees
+// billed to the session or key and the holds of its other requests. The response fee reservation
+// of the request is bounded by the cap applied. It returns the body to forward and the cap applied, 0 when the
+// request is not capped
+func (c *Ctrl) ClampMaxTokens(ctx *gin.Context, svc config.Service, route string, req model.Request, inputFee util.Amount, reqBody []byte) ([]byte, int64, error) {
+	if svc.OutputPrice.Sign() <= 0 {
+		return reqBody, 0, nil
+	}
+	handler, err := getRouteHandler(route)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(handler.maxTokensFields) == 0 {
+		return reqBody, 0, nil
+	}
+
+	var available *util.Amount
+	limit := "balance"
+	if svc.ClampMaxTokens {
+		balance, err := c.db.AvailableBalance(req.UserAddress, req.RequestHash)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "get available balance")
+		}
+		available = &balance
+	}
+	remaining, err := c.sessionRemainingSpend(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if remaining != nil && (available == nil || remaining.Cmp(*available) < 0) {
+		available, limit = remaining, "session spending cap"
+	}
+	remaining, err = c.apiKeyRemainingSpend(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if remaining != nil && (available == nil || remaining.Cmp(*available) < 0) {
+		available, limit = remaining, "API key spending cap"
+	}
+	if available == nil {
+		return reqBody, 0, nil
+	}
+
+	left := available.Sub(inputFee)
+	if left.Sign() <= 0 {
+		return nil, 0, fmt.Errorf("insufficient %s for any output token", limit)
+	}
+	// Output tokens are billed at OutputPrice per PriceUnit tokens
+	affordable := new(big.Int).Mul(left.Big(), big.NewInt(svc.PriceUnit))
+	affordable.Div(affordable, svc.OutputPrice.Big())
+	if affordable.Sign() == 0 {
+		return nil, 0, fmt.Errorf("insufficient %s for any output token", limit)
+	}
+	maxTokens := int64(math.MaxInt64)
+	if affordable.IsInt64() {
+		maxTokens = affordable.Int64()
+	}
+
+	unboundedAbove := int64(math.MaxInt64)
+	if svc.MaxOutputTokens > 0 {
+		unboundedAbove = svc.MaxOutputTokens
+	}
+	return clampBodyMaxTokens(reqBody, handler.maxTokensFields, maxTokens, unboundedAbove)
+}
+
+// bodyMaxTokens returns the smallest of the fields bounding the output tokens of a request, 0 when
+// none is set
+func bodyMaxTokens(reqBody []byte, fields []string) (int64, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(reqBody, &body); err != nil {
+		return 0, errors.Wrap(err, "failed to parse JSON body")
+	}
+	maxTokens := int64(0)
+	for _, field := range fields {
+		raw, ok := body[field]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		value, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", field, raw)
+		}
+		if maxTokens == 0 || value < maxTokens {
+			maxTokens = value
+		}
+	}
+	return maxTokens, nil
+}
+
+// clampBodyMaxTokens lowers the fields above maxTokens and sets the first field when none is
+// present, unless maxTokens is at least unboundedAbove. The cap the request runs with is returned
+func clampBodyMaxTokens(reqBody []byte, fields []string, maxTokens, unboundedAbove int64) ([]byte, int64, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(reqBody, &body); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to parse JSON body")
+	}
+
+	found := false
+	applied := int64(math.MaxInt64)
+	for _, field := range fields {
+		raw, ok := body[field]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		value, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid %s: %s", field, raw)
+		}
+		if value > maxTokens {
+			value = maxTokens
+			body[field] = json.RawMessage(strconv.FormatInt(value, 10))
+		}
+		found = true
+		applied = min(applied, value)
+	}
+	if !found {
+		if maxTokens >= unboundedAbove {
+			// The model bounds the output before the balance does
+			return reqBody, 0, nil
+		}
+		applied = maxTokens
+		body[fields[0]] = json.RawMessage(strconv.FormatInt(maxTokens, 10))
+	}
+
+	clamped, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "encode JSON body")
+	}
+	return clamped, applied, nil
+}
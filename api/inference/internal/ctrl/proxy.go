@@ -2,6 +2,7 @@ package ctrl
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -9,17 +10,23 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/config"
 	constant "github.com/0glabs/0g-serving-broker/inference/const"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 )
 
-func (c *Ctrl) PrepareHTTPRequest(ctx *gin.Context, targetURL string, reqBody []byte) (*http.Request, error) {
-	req, err := http.NewRequest(ctx.Request.Method, targetURL, io.NopCloser(bytes.NewBuffer(reqBody)))
+func (c *Ctrl) PrepareHTTPRequest(ctx *gin.Context, svc config.Service, targetURL string, reqBody []byte) (*http.Request, error) {
+	// Bound to the client request so that the upstream request is cancelled when the client disconnects
+	req, err := http.NewRequestWithContext(ctx.Request.Context(), ctx.Request.Method, targetURL, io.NopCloser(bytes.NewBuffer(reqBody)))
 	if err != nil {
 		return nil, err
 	}
 
 	for k, v := range ctx.Request.Header {
+		// A broker API key is not forwarded, the backend may expect its own token
+		if k == "Authorization" && bearerAPIKey(ctx) != "" {
+			continue
+		}
 		if _, ok := constant.RequestMetaDataDuplicate[k]; !ok {
 			req.Header.Set(k, v[0])
 			continue
@@ -27,7 +34,7 @@ func (c *Ctrl) PrepareHTTPRequest(ctx *gin.Context, targetURL string, reqBody []
 	}
 
 	// may need additional secret to access the target service
-	if additionalSecret := c.Service.AdditionalSecret; additionalSecret != nil {
+	if additionalSecret := svc.AdditionalSecret; additionalSecret != nil {
 		for k, v := range additionalSecret {
 			req.Header.Set(k, v)
 		}
@@ -36,8 +43,13 @@ func (c *Ctrl) PrepareHTTPRequest(ctx *gin.Context, targetURL string, reqBody []
 	return req, nil
 }
 
-func (c *Ctrl) ProcessHTTPRequest(ctx *gin.Context, svcType string, req *http.Request, reqModel model.Request, outputPrice int64, charing bool) error {
-	client := &http.Client{}
+func (c *Ctrl) ProcessHTTPRequest(ctx *gin.Context, svc config.Service, route string, req *http.Request, reqModel model.Request, charing bool) error {
+	client, ok := c.upstreams[svc.ModelType]
+	if !ok {
+		err := fmt.Errorf("no upstream client for model %s", svc.ModelType)
+		c.handleBrokerError(ctx, err, "call proxied service")
+		return err
+	}
 
 	// back up body for other usage
 	body, err := io.ReadAll(req.Body)
@@ -45,9 +57,10 @@ func (c *Ctrl) ProcessHTTPRequest(ctx *gin.Context, svcType string, req *http.Re
 		c.handleBrokerError(ctx, err, "failed to read request body")
 		return err
 	}
-	req.Body = io.NopCloser(bytes.NewBuffer(body))
 
-	resp, err := client.Do(req)
+	// A streamed response is written as it arrives, so only other requests can be retried
+	stream, _ := isStream(body)
+	resp, err := client.Do(req, body, !stream)
 	if err != nil {
 		c.handleBrokerError(ctx, err, "call proxied service")
 		return err
@@ -86,9 +99,9 @@ func (c *Ctrl) ProcessHTTPRequest(ctx *gin.Context, svcType string, req *http.Re
 		User: reqModel.UserAddress,
 	}
 
-	switch svcType {
+	switch svc.Type {
 	case "chatbot":
-		return c.handleChatbotResponse(ctx, resp, account, outputPrice, body, reqModel)
+		return c.handleChatbotResponse(ctx, resp, account, svc, body, reqModel, route)
 	default:
 		err = errors.New("unknown service type")
 		c.handleBrokerError(ctx, err, "prepare request extractor")
@@ -96,22 +109,6 @@ func (c *Ctrl) ProcessHTTPRequest(ctx *gin.Context, svcType string, req *http.Re
 	}
 }
 
-func (c *Ctrl) GetChatSignature(chatID string) (*ChatSignature, error) {
-	key := c.chatCacheKey(chatID)
-	c.logger.Debugf("get signature for chat: %v", chatID)
-	val, exist := c.svcCache.Get(key)
-	if !exist {
-		return nil, errors.New("Chat id not found or expired, chat_id_not_found")
-	}
-
-	chatSignature, ok := val.(ChatSignature)
-	if !ok {
-		return nil, errors.New("cached object does not implement ChatSignature")
-	}
-
-	return &chatSignature, nil
-}
-
 func (c *Ctrl) handleResponse(ctx *gin.Context, resp *http.Response) error {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -128,7 +125,7 @@ func (c *Ctrl) handleResponse(ctx *gin.Context, resp *http.Response) error {
 
 func (c *Ctrl) addExposeHeaders(ctx *gin.Context) {
 	// Set 'Access-Control-Expose-Headers' for CORS
-	exposeHeaders := []string{"Provider", "content-encoding"}
+	exposeHeaders := []string{"Provider", "content-encoding", constant.MaxTokensCapHeader}
 	existing := ctx.Writer.Header().Get("Access-Control-Expose-Headers")
 	var newHeaders string
 	if existing != "" {
@@ -0,0 +1,260 @@
+package ctrl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	"github.com/0glabs/0g-serving-broker/inference/internal/db"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+const (
+	// rateLimitOverrideExpiration is how long an override read from the database is trusted, other
+	// replicas of the broker pick up admin changes within this delay
+	rateLimitOverrideExpiration = time.Minute
+	// rateLimiterIdleTime is how long the state of an idle user is kept
+	rateLimiterIdleTime = 10 * time.Minute
+)
+
+// RateLimitError is returned when a user exceeds one of its limits, it is answered with a 429
+type RateLimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %s, retry after %s", e.Reason, e.RetryAfter)
+}
+
+// RetryAfterSeconds is the value of the Retry-After header
+func (e *RateLimitError) RetryAfterSeconds() int64 {
+	return int64(math.Max(1, math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// UserRateLimit is the effective limit of a user and the override it comes from, if any
+type UserRateLimit struct {
+	RequestsPerMinute    int64                `json:"requestsPerMinute"`
+	TokensPerMinute      int64                `json:"tokensPerMinute"`
+	MaxConcurrentStreams int64                `json:"maxConcurrentStreams"`
+	Override             *model.UserRateLimit `json:"override,omitempty"`
+}
+
+// bucket refills limit units per minute up to limit
+type bucket struct {
+	level float64
+	last  time.Time
+}
+
+func (b *bucket) refill(now time.Time, limit int64) {
+	if b.last.IsZero() {
+		b.level = float64(limit)
+	} else {
+		b.level += now.Sub(b.last).Minutes() * float64(limit)
+	}
+	b.level = math.Min(b.level, float64(limit))
+	b.last = now
+}
+
+// wait is how long it takes for the level to reach want
+func (b *bucket) wait(want float64, limit int64) time.Duration {
+	return time.Duration((want - b.level) / float64(limit) * float64(time.Minute))
+}
+
+type userLimiter struct {
+	requests bucket
+	tokens   bucket
+	streams  int64
+	lastSeen time.Time
+}
+
+// rateLimiter keeps the per user state of this broker in memory
+type rateLimiter struct {
+	mu        sync.Mutex
+	defaults  config.RateLimit
+	users     map[string]*userLimiter
+	overrides *cache.Cache
+	lastSweep time.Time
+}
+
+func newRateLimiter(defaults config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		defaults:  defaults,
+		users:     make(map[string]*userLimiter),
+		overrides: cache.New(rateLimitOverrideExpiration, 2*rateLimitOverrideExpiration),
+	}
+}
+
+// effectiveRateLimit merges the override of a user into the defaults
+func (c *Ctrl) effectiveRateLimit(userAddress string) (UserRateLimit, error) {
+	key := strings.ToLower(userAddress)
+	defaults := c.rateLimiter.defaults
+	limit := UserRateLimit{
+		RequestsPerMinute:    defaults.RequestsPerMinute,
+		TokensPerMinute:      defaults.TokensPerMinute,
+		MaxConcurrentStreams: defaults.MaxConcurrentStreams,
+	}
+
+	var override *model.UserRateLimit
+	if val, ok := c.rateLimiter.overrides.Get(key); ok {
+		override, _ = val.(*model.UserRateLimit)
+	} else {
+		o, err := c.db.GetUserRateLimit(key)
+		if db.IgnoreNotFound(err) != nil {
+			return limit, errors.Wrap(err, "get user rate limit")
+		}
+		if err == nil {
+			override = &o
+		}
+		// A missing override is cached as well, so that limited users do not hit the database
+		c.rateLimiter.overrides.Set(key, override, cache.DefaultExpiration)
+	}
+
+	if override != nil {
+		limit.Override = override
+		if override.RequestsPerMinute != nil {
+			limit.RequestsPerMinute = *override.RequestsPerMinute
+		}
+		if override.TokensPerMinute != nil {
+			limit.TokensPerMinute = *override.TokensPerMinute
+		}
+		if override.MaxConcurrentStreams != nil {
+			limit.MaxConcurrentStreams = *override.MaxConcurrentStreams
+		}
+	}
+	return limit, nil
+}
+
+// AdmitRequest checks the limits of the user before its request is created. The tokens of the
+// request are charged once it completes, so a user is admitted while its token budget is positive.
+// The returned release must be called when the request is done
+func (c *Ctrl) AdmitRequest(userAddress string, reqBody []byte) (func(), error) {
+	limit, err := c.effectiveRateLimit(userAddress)
+	if err != nil {
+		return nil, err
+	}
+	stream, _ := isStream(reqBody)
+
+	r := c.rateLimiter
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+	key := strings.ToLower(userAddress)
+	u, ok := r.users[key]
+	if !ok {
+		u = &userLimiter{}
+		r.users[key] = u
+	}
+	u.lastSeen = now
+
+	if stream && limit.MaxConcurrentStreams > 0 && u.streams >= limit.MaxConcurrentStreams {
+		// The stream duration is unknown, the client is asked to retry shortly
+		return nil, &RateLimitError{
+			Reason:     fmt.Sprintf("%d concurrent streams allowed", limit.MaxConcurrentStreams),
+			RetryAfter: time.Second,
+		}
+	}
+	if limit.RequestsPerMinute > 0 {
+		u.requests.refill(now, limit.RequestsPerMinute)
+		if u.requests.level < 1 {
+			return nil, &RateLimitError{
+				Reason:     fmt.Sprintf("%d requests per minute allowed", limit.RequestsPerMinute),
+				RetryAfter: u.requests.wait(1, limit.RequestsPerMinute),
+			}
+		}
+	}
+	if limit.TokensPerMinute > 0 {
+		u.tokens.refill(now, limit.TokensPerMinute)
+		if u.tokens.level <= 0 {
+			return nil, &RateLimitError{
+				Reason:     fmt.Sprintf("%d tokens per minute allowed", limit.TokensPerMinute),
+				RetryAfter: u.tokens.wait(1, limit.TokensPerMinute),
+			}
+		}
+	}
+
+	if limit.RequestsPerMinute > 0 {
+		u.requests.level--
+	}
+	if !stream {
+		return func() {}, nil
+	}
+	u.streams++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			u.streams--
+			u.lastSeen = time.Now()
+		})
+	}, nil
+}
+
+// chargeTokens takes the billed tokens of a completed request from the budget of the user
+func (c *Ctrl) chargeTokens(userAddress string, usage *Usage) {
+	limit, err := c.effectiveRateLimit(userAddress)
+	if err != nil {
+		c.logger.Errorf("charge tokens to the rate limit of %s: %v", userAddress, err)
+		return
+	}
+	if limit.TokensPerMinute <= 0 {
+		return
+	}
+
+	r := c.rateLimiter
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[strings.ToLower(userAddress)]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	u.tokens.refill(now, limit.TokensPerMinute)
+	u.tokens.level -= float64(usage.PromptTokens + usage.CompletionTokens)
+	u.lastSeen = now
+}
+
+// sweep forgets the users idle for a while, r.mu must be held
+func (r *rateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < rateLimiterIdleTime {
+		return
+	}
+	r.lastSweep = now
+	for key, u := range r.users {
+		if u.streams == 0 && now.Sub(u.lastSeen) > rateLimiterIdleTime {
+			delete(r.users, key)
+		}
+	}
+}
+
+func (c *Ctrl) GetUserRateLimit(userAddress string) (UserRateLimit, error) {
+	c.rateLimiter.overrides.Delete(strings.ToLower(userAddress))
+	return c.effectiveRateLimit(userAddress)
+}
+
+func (c *Ctrl) SetUserRateLimit(limit model.UserRateLimit) error {
+	limit.User = strings.ToLower(limit.User)
+	if err := c.db.PutUserRateLimit(limit); err != nil {
+		return errors.Wrap(err, "put user rate limit")
+	}
+	c.rateLimiter.overrides.Delete(limit.User)
+	return nil
+}
+
+func (c *Ctrl) DeleteUserRateLimit(userAddress string) error {
+	key := strings.ToLower(userAddress)
+	if err := c.db.DeleteUserRateLimit(key); err != nil {
+		return errors.Wrap(err, "delete user rate limit")
+	}
+	c.rateLimiter.overrides.Delete(key)
+	return nil
+}
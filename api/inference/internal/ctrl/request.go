@@ -8,14 +8,15 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gin-gonic/gin"
 	"github.com/patrickmn/go-cache"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
 	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
 	constant "github.com/0glabs/0g-serving-broker/inference/const"
+	"github.com/0glabs/0g-serving-broker/inference/internal/db"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 )
 
@@ -26,8 +27,25 @@ type SessionToken struct {
 	Timestamp int64  `json:"timestamp"`
 	ExpiresAt int64  `json:"expiresAt"`
 	Nonce     string `json:"nonce"`
+	// ChainID and Contract bind the token to one deployment of the serving contract
+	ChainID  int64  `json:"chainId"`
+	Contract string `json:"contract"`
+	// Issuer is empty for the tokens signed by the user, and SessionIssuerSIWE for the tokens signed
+	// by the broker after a SIWE login
+	Issuer string `json:"issuer,omitempty"`
+	// MaxSpend optionally caps the fees billed to the session in neuron, and Models optionally
+	// restricts the models it can call, so that a token can be handed to a less trusted client
+	MaxSpend string   `json:"maxSpend,omitempty"`
+	Models   []string `json:"models,omitempty"`
+	// Priority lowers the admission priority of the session, e.g. for batch jobs. It cannot raise it
+	// above the priority configured for the user
+	Priority *int `json:"priority,omitempty"`
 }
 
+// balanceHoldLifetime bounds the time a request holds balance if its broker stops before
+// releasing the hold
+const balanceHoldLifetime = time.Hour
+
 // SessionValidationCache stores validated sessions to avoid repeated signature verification
 // Since the cache key contains all validation data, we only need to store minimal info
 type SessionValidationCache struct {
@@ -38,10 +56,10 @@ func (c *Ctrl) CreateRequest(req model.Request) error {
 	return errors.Wrap(c.db.CreateRequest(req), "create request in db")
 }
 
-func (c *Ctrl) ListRequest(q model.RequestListOptions) ([]model.Request, int, error) {
+func (c *Ctrl) ListRequest(q model.RequestListOptions) ([]model.Request, util.Amount, error) {
 	list, fee, err := c.db.ListRequest(q)
 	if err != nil {
-		return nil, 0, errors.Wrap(err, "list service from db")
+		return nil, util.Amount{}, errors.Wrap(err, "list service from db")
 	}
 	return list, fee, nil
 }
@@ -50,6 +68,23 @@ func (c *Ctrl) GetFromHTTPRequest(ctx *gin.Context) (model.Request, error) {
 	var req model.Request
 	headerMap := ctx.Request.Header
 
+	// A broker API key replaces the session headers, the request is billed to the account of its user
+	if token := bearerAPIKey(ctx); token != "" {
+		key, err := c.getAPIKey(token)
+		if err != nil {
+			return req, err
+		}
+		// Keys minted before the addresses were checksummed hold a lowercase address
+		req.UserAddress = common.HexToAddress(key.User).Hex()
+		req.APIKeyID = key.ID
+		if value := headerMap.Get("VLLM-Proxy"); value != "" {
+			if err := updateRequestField(&req, "VLLM-Proxy", value); err != nil {
+				return req, err
+			}
+		}
+		return req, nil
+	}
+
 	for k := range constant.RequestMetaData {
 		values := headerMap.Values(k)
 		if len(values) == 0 && k != "VLLM-Proxy" {
@@ -97,6 +132,9 @@ func (c *Ctrl) ValidateSession(ctx *gin.Context) error {
 	if time.Now().Unix() > token.ExpiresAt/1000 {
 		return errors.New("session token expired")
 	}
+	if err := c.checkSessionToken(token); err != nil {
+		return err
+	}
 	
 	// Create hash values for secure caching
 	tokenHashValue := crypto.Keccak256Hash([]byte(tokenStr)).Hex()
@@ -104,44 +142,24 @@ func (c *Ctrl) ValidateSession(ctx *gin.Context) error {
 	
 	// Check session cache to avoid repeated signature verification
 	// Use a more secure cache key that includes token and signature hashes
-	cacheKey := fmt.Sprintf("%s:%s:%s:%s", address, token.Nonce, tokenHashValue, signatureHashValue)
+	cacheKey := fmt.Sprintf("%s:%s:%s:%s", strings.ToLower(address), token.Nonce, tokenHashValue, signatureHashValue)
 	if _, found := c.sessionCache.Get(cacheKey); found {
 		// Cache key already contains all verification data (address, nonce, token hash, signature hash)
 		// If found, it means this exact combination was already validated
 		return nil
 	}
 	
-	// Verify signature following the same pattern as verifySignature in setup.go
-	messageHash := crypto.Keccak256Hash([]byte(tokenStr))
-	
-	// Create Ethereum personal message hash (matches the client signMessage behavior)
-	// Following the same pattern as getHash in setup.go
-	prefixedMsg := crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), messageHash.Bytes())
-	
-	// Decode signature from hex
-	sigBytes, err := hexutil.Decode(signature)
+	signer, err := c.sessionSigner(token, address)
 	if err != nil {
-		return errors.Wrap(err, "invalid signature format")
-	}
-	
-	// Ethereum signatures are 65 bytes: R (32) + S (32) + V (1)
-	if len(sigBytes) != 65 {
-		return errors.New("invalid signature length")
+		return err
 	}
-	
-	// Adjust V value for Ethereum signature recovery (same as verifySignature)
-	v1 := sigBytes[64] - 27
-	pubKey, err := crypto.SigToPub(prefixedMsg.Bytes(), append(sigBytes[:64], v1))
-	if err != nil {
-		return errors.Wrap(err, "failed to recover public key from signature")
+	if err := c.verifyPersonalSignature(ctx, []byte(tokenStr), signature, signer); err != nil {
+		return err
 	}
 	
-	// Get address from public key
-	recoveredAddr := crypto.PubkeyToAddress(*pubKey)
-	
-	// Verify recovered address matches claimed address (same as verifySignature)
-	if !strings.EqualFold(recoveredAddr.Hex(), address) {
-		return errors.New("signature verification failed: address mismatch")
+	// The nonce is bound to this token, a revoked session is rejected
+	if err := c.registerSession(token, tokenHashValue); err != nil {
+		return err
 	}
 	
 	// Cache the validated session
@@ -155,11 +173,18 @@ func (c *Ctrl) ValidateSession(ctx *gin.Context) error {
 }
 
 // ValidateRequestWithEstimatedFee validates the request using an estimated fee
-// This is used before the actual token count is known from the LLM
-func (c *Ctrl) ValidateRequestWithEstimatedFee(ctx *gin.Context, req model.Request, estimatedFee string) error {
-	// First validate the session token
-	if err := c.ValidateSession(ctx); err != nil {
+// This is used before the actual token count is known from the LLM. The balance is reserved later
+// with ReserveBalance, once the request is admitted
+func (c *Ctrl) ValidateRequestWithEstimatedFee(ctx *gin.Context, svc config.Service, req model.Request, estimatedFee util.Amount) error {
+	// First validate the session token, or the API key replacing it
+	if req.APIKeyID != "" {
+		if err := c.validateAPIKey(ctx, svc, req, estimatedFee); err != nil {
+			return errors.Wrap(err, "API key validation failed")
+		}
+	} else if err := c.ValidateSession(ctx); err != nil {
 		return errors.Wrap(err, "session validation failed")
+	} else if err := c.checkSessionLimits(ctx, svc, req, estimatedFee); err != nil {
+		return errors.Wrap(err, "session limit")
 	}
 	
 	contractAccount, err := c.contract.GetUserAccount(ctx, common.HexToAddress(req.UserAddress))
@@ -171,87 +196,102 @@ func (c *Ctrl) ValidateRequestWithEstimatedFee(ctx *gin.Context, req model.Reque
 		return errors.New("user not acknowledge the provider")
 	}
 
+	_, err = c.GetOrCreateAccount(ctx, req.UserAddress)
+	return err
+}
+
+// ReserveBalance holds the balance an admitted request needs, from its estimated fee, until the
+// request is billed or ReleaseBalanceHold is called
+func (c *Ctrl) ReserveBalance(ctx *gin.Context, svc config.Service, route string, req model.Request, reqBody []byte, estimatedFee util.Amount) error {
 	account, err := c.GetOrCreateAccount(ctx, req.UserAddress)
 	if err != nil {
 		return err
 	}
+	return c.reserveBalance(ctx, svc, route, account, req, reqBody, estimatedFee)
+}
 
-	// Use estimated fee for validation
-	err = c.validateBalanceAdequacy(ctx, account, estimatedFee)
+// reserveBalance places a hold of the input fee and the response fee reservation on the locked
+// balance of the user. The hold is checked against the unsettled fees and the holds of the other
+// requests in flight, and against the spending cap of the session or API key of the request,
+// atomically. The account is synchronized with the contract once when the hold does not fit in the
+// balance
+func (c *Ctrl) reserveBalance(ctx *gin.Context, svc config.Service, route string, account model.User, req model.Request, reqBody []byte, fee util.Amount) error {
+	handler, err := getRouteHandler(route)
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-
-func (c *Ctrl) validateBalanceAdequacy(ctx *gin.Context, account model.User, fee string) error {
-	if account.LockBalance == nil {
-		return errors.New("nil lockBalance in account")
-	}
-
-	// Calculate response fee reservation
-	responseFeeReservation, err := util.Multiply(c.Service.OutputPrice, constant.ResponseFeeReservationFactor)
+	// Calculate response fee reservation, the output is billed the way the route bills it
+	reservedOutput, err := handler.reservedOutput(reqBody)
 	if err != nil {
-		return errors.Wrap(err, "calculate response fee reservation")
+		return err
 	}
-
-	// Use optimized calculation for unsettled fee using database aggregation
-	unsettledFee, err := c.db.CalculateUnsettledFee(account.User, c.Service.InputPrice, c.Service.OutputPrice)
-	if err != nil {
-		return errors.Wrap(err, "calculate unsettled fee")
+	if len(handler.maxTokensFields) > 0 {
+		// The output cannot exceed the max tokens of the request nor of the model
+		maxTokens, err := bodyMaxTokens(reqBody, handler.maxTokensFields)
+		if err != nil {
+			return err
+		}
+		if maxTokens > 0 {
+			reservedOutput = min(reservedOutput, maxTokens)
+		}
+		if svc.MaxOutputTokens > 0 {
+			reservedOutput = min(reservedOutput, svc.MaxOutputTokens)
+		}
 	}
-
-	// Add input fee, unsettled fee, and response fee reservation
-	totalWithInput, err := util.Add(fee, unsettledFee.String())
-	if err != nil {
-		return err
+	_, responseFeeReservation := handler.fee(&Usage{CompletionTokens: int(reservedOutput)}, svc.InputPrice, svc.OutputPrice, svc.PriceUnit)
+	hold := model.BalanceHold{
+		RequestHash:  req.RequestHash,
+		User:         account.User,
+		Amount:       fee.Add(responseFeeReservation),
+		SessionNonce: req.SessionNonce,
+		APIKeyID:     req.APIKeyID,
+		ExpiresAt:    time.Now().Add(balanceHoldLifetime),
 	}
-	total, err := util.Add(totalWithInput, responseFeeReservation)
+	sessionCap, err := sessionMaxSpend(ctx, req)
 	if err != nil {
 		return err
 	}
 
-	cmp1, err := util.Compare(total, account.LockBalance)
-	if err != nil {
+	_, err = c.db.PlaceHold(hold, sessionCap)
+	if errors.Is(err, db.ErrSpendingCapReached) {
+		ctx.Set("ignoreError", true)
 		return err
 	}
-	if cmp1 <= 0 {
-		return nil
+	if !errors.Is(err, db.ErrInsufficientBalance) {
+		return errors.Wrap(err, "place balance hold")
 	}
 
 	// reload account and repeat the check
 	if err := c.SyncUserAccount(ctx, common.HexToAddress(account.User)); err != nil {
 		return err
 	}
-	newAccount, err := c.GetOrCreateAccount(ctx, account.User)
-	if err != nil {
+	required, err := c.db.PlaceHold(hold, sessionCap)
+	if errors.Is(err, db.ErrSpendingCapReached) {
+		ctx.Set("ignoreError", true)
 		return err
 	}
-	
-	// Recalculate unsettled fee after sync using optimized method
-	unsettledFeeNew, err := c.db.CalculateUnsettledFee(account.User, c.Service.InputPrice, c.Service.OutputPrice)
-	if err != nil {
-		return errors.Wrap(err, "recalculate unsettled fee")
+	if !errors.Is(err, db.ErrInsufficientBalance) {
+		return errors.Wrap(err, "place balance hold")
 	}
-	
-	totalWithInputNew, err := util.Add(fee, unsettledFeeNew.String())
-	if err != nil {
-		return err
-	}
-	totalNew, err := util.Add(totalWithInputNew, responseFeeReservation)
-	if err != nil {
-		return err
-	}
-	cmp2, err := util.Compare(totalNew, newAccount.LockBalance)
+	newAccount, err := c.GetOrCreateAccount(ctx, account.User)
 	if err != nil {
 		return err
 	}
-	if cmp2 <= 0 {
-		return nil
-	}
 	ctx.Set("ignoreError", true)
-	return fmt.Errorf("insufficient balance, total fee of %s (including response reservation) exceeds the available balance of %s", totalNew.String(), *newAccount.LockBalance)
+	return fmt.Errorf("insufficient balance, total fee of %s (including response reservations of the requests in flight) exceeds the available balance of %s", required, newAccount.LockBalance)
+}
+
+// ReleaseBalanceHold releases the hold of a request that was not billed, the hold of a billed
+// request was already converted into its fee
+func (c *Ctrl) ReleaseBalanceHold(requestHash string) {
+	if err := c.db.ReleaseHold(requestHash); err != nil {
+		c.logger.Errorf("release balance hold of request %s: %v", requestHash, err)
+	}
+}
+
+// PruneBalanceHolds forgets the expired holds of requests that were never released
+func (c *Ctrl) PruneBalanceHolds() error {
+	return errors.Wrap(c.db.PruneHolds(time.Now()), "prune balance holds")
 }
 
 func updateRequestField(req *model.Request, key, value string) error {
@@ -264,7 +304,14 @@ func updateRequestField(req *model.Request, key, value string) error {
 			v = false
 		}
 		req.VLLMProxy = v
-	case "Session-Token", "Session-Signature":
+	case "Session-Token":
+		// The token is validated in ValidateSession, its nonce identifies the session billed
+		var token SessionToken
+		if err := json.Unmarshal([]byte(value), &token); err == nil {
+			req.SessionNonce = token.Nonce
+		}
+		return nil
+	case "Session-Signature":
 		// These headers are used for validation only, not stored in the request
 		// They are processed in ValidateSession method
 		return nil
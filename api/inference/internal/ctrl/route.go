@@ -0,0 +1,239 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/common/util"
+	constant "github.com/0glabs/0g-serving-broker/inference/const"
+	"github.com/0glabs/0g-serving-broker/inference/internal/tokenizer"
+)
+
+// routeHandler bundles how a billable OpenAI-compatible route is parsed and charged
+type routeHandler struct {
+	// inputCount counts the input units of a request body, used ONLY for balance validation.
+	// tk is nil when the service has no tokenizer configured
+	inputCount func(reqBody []byte, tk tokenizer.Tokenizer) (int64, error)
+	// extractUsage decodes a single JSON payload (a whole response or one stream event) and
+	// returns the usage it reports, nil if absent, together with the output text it carries
+	extractUsage func(payload []byte) (*Usage, string, error)
+	// fee converts usage into the input fee and output fee, at prices per priceUnit tokens
+	fee func(usage *Usage, inputPrice, outputPrice util.Amount, priceUnit int64) (util.Amount, util.Amount)
+	// reservedOutput is the output units held for a request until it is billed, tokens or images
+	reservedOutput func(reqBody []byte) (int64, error)
+	// maxTokensFields lists the body fields bounding the output tokens, the first one is set when
+	// none is present. It is empty for the routes whose output is not billed per token
+	maxTokensFields []string
+}
+
+// routeHandlers must stay aligned with constant.TargetRoute
+var routeHandlers = map[string]*routeHandler{
+	"/chat/completions": {
+		inputCount:      chatInputCount,
+		extractUsage:    extractCompletionUsage,
+		fee:             tokenFee,
+		reservedOutput:  reservedOutputTokens,
+		maxTokensFields: []string{"max_tokens", "max_completion_tokens"},
+	},
+	"/completions": {
+		inputCount:      fieldInputCount("prompt"),
+		extractUsage:    extractCompletionUsage,
+		fee:             tokenFee,
+		reservedOutput:  reservedOutputTokens,
+		maxTokensFields: []string{"max_tokens"},
+	},
+	"/embeddings": {
+		inputCount:     fieldInputCount("input"),
+		extractUsage:   extractEmbeddingUsage,
+		fee:            inputTokenFee,
+		reservedOutput: func([]byte) (int64, error) { return 0, nil },
+	},
+	"/images/generations": {
+		inputCount:     func([]byte, tokenizer.Tokenizer) (int64, error) { return 0, nil },
+		extractUsage:   extractImageUsage,
+		fee:            perImageFee,
+		reservedOutput: reservedImages,
+	},
+}
+
+// reservedOutputTokens holds the response fee reservation of the token routes
+func reservedOutputTokens([]byte) (int64, error) {
+	return constant.ResponseFeeReservationFactor, nil
+}
+
+// reservedImages holds the images requested, n in the body, 1 by default
+func reservedImages(reqBody []byte) (int64, error) {
+	var body struct {
+		N *int64 `json:"n"`
+	}
+	if err := json.Unmarshal(reqBody, &body); err != nil {
+		return 0, errors.Wrap(err, "failed to parse JSON body")
+	}
+	if body.N == nil {
+		return 1, nil
+	}
+	if *body.N < 1 {
+		return 0, fmt.Errorf("invalid n: %d", *body.N)
+	}
+	return *body.N, nil
+}
+
+func getRouteHandler(route string) (*routeHandler, error) {
+	h, ok := routeHandlers[route]
+	if !ok {
+		return nil, fmt.Errorf("route %s is not supported", route)
+	}
+	return h, nil
+}
+
+const (
+	// chatMessageOverhead is the number of tokens a chat template adds around each message
+	chatMessageOverhead = 4
+	// imagePartTokens is charged for every image part, the cost of a low detail image with OpenAI
+	imagePartTokens = 85
+)
+
+// fieldInputCount counts the tokens of a body field, falling back to an estimation based on its
+// byte size when no tokenizer is configured. The actual token count from LLM response will replace it
+func fieldInputCount(field string) func(reqBody []byte, tk tokenizer.Tokenizer) (int64, error) {
+	return func(reqBody []byte, tk tokenizer.Tokenizer) (int64, error) {
+		value, err := bodyField(reqBody, field)
+		if err != nil {
+			return 0, err
+		}
+		if tk == nil {
+			// Estimation based on field byte length for validation only
+			return int64(len(value)), nil
+		}
+		return countTextTokens(value, tk), nil
+	}
+}
+
+// chatInputCount counts every message part: text, tool calls and results, reasoning and images,
+// plus the tool definitions. Without a tokenizer text is estimated by its byte length, so that
+// inline image data does not inflate the estimation
+func chatInputCount(reqBody []byte, tk tokenizer.Tokenizer) (int64, error) {
+	if _, err := bodyField(reqBody, "messages"); err != nil {
+		return 0, err
+	}
+	var body RequestBody
+	if err := json.Unmarshal(reqBody, &body); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal messages: %w", err)
+	}
+
+	count := func(text string) int64 {
+		if tk == nil {
+			return int64(len(text))
+		}
+		return int64(tk.CountTokens(text))
+	}
+
+	var total int64
+	for _, message := range body.Messages {
+		total += chatMessageOverhead + count(message.Role) + count(message.Name) + count(message.ToolCallID) +
+			count(message.billableText()) + int64(message.Content.Images())*imagePartTokens
+	}
+	for _, tool := range body.Tools {
+		total += count(string(tool))
+	}
+	return total, nil
+}
+
+func bodyField(reqBody []byte, field string) (json.RawMessage, error) {
+	var bodyMap map[string]json.RawMessage
+	if err := json.Unmarshal(reqBody, &bodyMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reqBody: %w", err)
+	}
+	value, ok := bodyMap[field]
+	if !ok {
+		return nil, fmt.Errorf("%s field not found in reqBody", field)
+	}
+	return value, nil
+}
+
+// countTextTokens counts the tokens of a prompt-like value: a string, a list of strings,
+// a list of token ids, or a list of content parts carrying a text field
+func countTextTokens(value json.RawMessage, tk tokenizer.Tokenizer) int64 {
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		return int64(tk.CountTokens(text))
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(value, &items); err == nil {
+		var count int64
+		for _, item := range items {
+			var id int64
+			if err := json.Unmarshal(item, &id); err == nil {
+				count++
+				continue
+			}
+			count += countTextTokens(item, tk)
+		}
+		return count
+	}
+
+	var part struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(value, &part); err == nil {
+		return int64(tk.CountTokens(part.Text))
+	}
+	return 0
+}
+
+// extractCompletionUsage handles both chat completions and legacy text completions
+func extractCompletionUsage(payload []byte) (*Usage, string, error) {
+	var chunk CompletionChunk
+	if err := json.Unmarshal(payload, &chunk); err != nil {
+		return nil, "", errors.Wrap(err, "Error unmarshaling JSON")
+	}
+
+	var output strings.Builder
+	for _, choice := range chunk.Choices {
+		output.WriteString(choice.billableText())
+	}
+	return chunk.Usage, output.String(), nil
+}
+
+func extractEmbeddingUsage(payload []byte) (*Usage, string, error) {
+	var resp struct {
+		Usage *Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, "", errors.Wrap(err, "Error unmarshaling JSON")
+	}
+	return resp.Usage, "", nil
+}
+
+// extractImageUsage reports the number of generated images as completion units
+func extractImageUsage(payload []byte) (*Usage, string, error) {
+	var resp struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, "", errors.Wrap(err, "Error unmarshaling JSON")
+	}
+	return &Usage{
+		CompletionTokens: len(resp.Data),
+		TotalTokens:      len(resp.Data),
+	}, "", nil
+}
+
+func tokenFee(usage *Usage, inputPrice, outputPrice util.Amount, priceUnit int64) (util.Amount, util.Amount) {
+	return util.TokenFee(inputPrice, int64(usage.PromptTokens), priceUnit),
+		util.TokenFee(outputPrice, int64(usage.CompletionTokens), priceUnit)
+}
+
+// inputTokenFee charges only the prompt, embeddings produce no output tokens
+func inputTokenFee(usage *Usage, inputPrice, _ util.Amount, priceUnit int64) (util.Amount, util.Amount) {
+	return util.TokenFee(inputPrice, int64(usage.PromptTokens), priceUnit), util.NewAmount(0)
+}
+
+// perImageFee charges the output price for every generated image, the price unit applies to tokens
+// only
+func perImageFee(usage *Usage, _, outputPrice util.Amount, _ int64) (util.Amount, util.Amount) {
+	return util.NewAmount(0), outputPrice.MulInt64(int64(usage.CompletionTokens))
+}
@@ -18,7 +18,7 @@ func (c *Ctrl) GetService(ctx context.Context) (model.Service, error) {
 }
 
 func (c *Ctrl) SyncService(ctx context.Context) error {
-	if err := c.contract.SyncService(ctx, c.Service); err != nil {
+	if err := c.contract.SyncService(ctx, c.Services); err != nil {
 		return errors.Wrap(err, "sync services")
 	}
 	return nil
@@ -0,0 +1,228 @@
+package ctrl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// sessionRevocationMaxAge bounds the age of a revocation, so that it cannot be replayed later to
+// revoke a new session reusing the nonce
+const sessionRevocationMaxAge = 5 * time.Minute
+
+// SessionRevocation is signed by a user to revoke one of its sessions
+type SessionRevocation struct {
+	Address  string `json:"address"`
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	// Timestamp is in milliseconds, like in SessionToken
+	Timestamp int64  `json:"timestamp"`
+	ChainID   int64  `json:"chainId"`
+	Contract  string `json:"contract"`
+}
+
+// RevokeSessionRequest carries the revocation as the JSON string that was signed
+type RevokeSessionRequest struct {
+	Revocation string `json:"revocation" binding:"required"`
+	Signature  string `json:"signature" binding:"required"`
+}
+
+// verifyPersonalSignature checks that address signed keccak256(message) as a personal message,
+// which is how clients sign session tokens. Contract wallets are checked with EIP-1271
+func (c *Ctrl) verifyPersonalSignature(ctx context.Context, message []byte, sig, address string) error {
+	messageHash := crypto.Keccak256Hash(message)
+	prefixedMsg := crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), messageHash.Bytes())
+
+	sigBytes, err := hexutil.Decode(sig)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature format")
+	}
+	if !common.IsHexAddress(address) {
+		return errors.New("invalid signer address")
+	}
+	if _, err := c.contract.SignatureVerifier.Verify(ctx, prefixedMsg.Bytes(), sigBytes, common.HexToAddress(address)); err != nil {
+		return errors.Wrap(err, "signature verification failed")
+	}
+	return nil
+}
+
+// checkDeployment rejects a token signed for another chain or serving contract
+func (c *Ctrl) checkDeployment(chainID int64, contract string) error {
+	if c.contract.ChainID == nil || !c.contract.ChainID.IsInt64() || c.contract.ChainID.Int64() != chainID {
+		return errors.New("session token is for a different chain")
+	}
+	if !common.IsHexAddress(contract) || common.HexToAddress(contract) != c.contract.ContractAddress {
+		return errors.New("session token is for a different contract")
+	}
+	return nil
+}
+
+// checkSessionToken enforces the deployment binding, the nonce and the maximum lifetime of a token
+func (c *Ctrl) checkSessionToken(token SessionToken) error {
+	if err := c.checkDeployment(token.ChainID, token.Contract); err != nil {
+		return err
+	}
+	if token.Nonce == "" {
+		return errors.New("session token has no nonce")
+	}
+	if token.MaxSpend != "" {
+		if maxSpend, err := util.ParseAmount(token.MaxSpend); err != nil || maxSpend.Sign() < 0 {
+			return fmt.Errorf("invalid session maxSpend %s", token.MaxSpend)
+		}
+	}
+	if c.sessionMaxLifetime > 0 && time.Duration(token.ExpiresAt-token.Timestamp)*time.Millisecond > c.sessionMaxLifetime {
+		return fmt.Errorf("session token lifetime exceeds %s", c.sessionMaxLifetime)
+	}
+	return nil
+}
+
+// registerSession binds the nonce of the token to it the first time it is used
+func (c *Ctrl) registerSession(token SessionToken, tokenHash string) error {
+	session, err := c.db.RegisterSession(model.Session{
+		Address:   strings.ToLower(token.Address),
+		Nonce:     token.Nonce,
+		TokenHash: tokenHash,
+		ExpiresAt: time.UnixMilli(token.ExpiresAt),
+	})
+	if err != nil {
+		return errors.Wrap(err, "register session")
+	}
+	if session.Revoked != nil && *session.Revoked {
+		return errors.New("session token revoked")
+	}
+	if session.TokenHash != tokenHash {
+		return errors.New("session nonce already used by another token")
+	}
+	return nil
+}
+
+// checkSessionLimits enforces the optional model restriction and spending cap of the session token
+// of a request, which has been validated before. It turns away early the requests the cap cannot
+// pay for, the cap is enforced when the balance of the request is held
+func (c *Ctrl) checkSessionLimits(ctx *gin.Context, svc config.Service, req model.Request, estimatedFee util.Amount) error {
+	var token SessionToken
+	if err := json.Unmarshal([]byte(ctx.GetHeader("Session-Token")), &token); err != nil {
+		return errors.Wrap(err, "invalid session token format")
+	}
+	if len(token.Models) > 0 && !slices.Contains(token.Models, svc.ModelType) {
+		return fmt.Errorf("session is not allowed to call model %s", svc.ModelType)
+	}
+
+	remaining, err := c.sessionRemainingSpend(ctx, req)
+	if err != nil {
+		return err
+	}
+	if remaining != nil && estimatedFee.Cmp(*remaining) > 0 {
+		return fmt.Errorf("session spending cap of %s reached, %s left after the spending and the requests in flight", token.MaxSpend, *remaining)
+	}
+	return nil
+}
+
+// sessionMaxSpend returns the spending cap of the session token of a request, nil when the request
+// has no session or its session has no cap
+func sessionMaxSpend(ctx *gin.Context, req model.Request) (*util.Amount, error) {
+	if req.SessionNonce == "" {
+		return nil, nil
+	}
+	var token SessionToken
+	if err := json.Unmarshal([]byte(ctx.GetHeader("Session-Token")), &token); err != nil {
+		return nil, errors.Wrap(err, "invalid session token format")
+	}
+	if token.MaxSpend == "" {
+		return nil, nil
+	}
+	maxSpend, err := util.ParseAmount(token.MaxSpend)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid session maxSpend")
+	}
+	return &maxSpend, nil
+}
+
+// sessionRemainingSpend returns what the spending cap of the session of a request leaves for it,
+// after the fees billed to the session and the holds of its other requests in flight. It is nil
+// when the session has no cap
+func (c *Ctrl) sessionRemainingSpend(ctx *gin.Context, req model.Request) (*util.Amount, error) {
+	maxSpend, err := sessionMaxSpend(ctx, req)
+	if err != nil || maxSpend == nil {
+		return nil, err
+	}
+	session, err := c.db.GetSession(strings.ToLower(req.UserAddress), req.SessionNonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "get session")
+	}
+	held, err := c.db.SessionHeld(req.UserAddress, req.SessionNonce, req.RequestHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "get session holds")
+	}
+	remaining := maxSpend.Sub(session.Spent).Sub(held)
+	return &remaining, nil
+}
+
+// RevokeSession revokes a session of the user who signed the revocation. Other broker replicas may
+// accept the session until their validation cache expires
+func (c *Ctrl) RevokeSession(ctx context.Context, req RevokeSessionRequest) error {
+	var revocation SessionRevocation
+	if err := json.Unmarshal([]byte(req.Revocation), &revocation); err != nil {
+		return errors.Wrap(err, "invalid session revocation format")
+	}
+	if !strings.EqualFold(revocation.Provider, c.contract.ProviderAddress) {
+		return errors.New("session revocation is for different provider")
+	}
+	if err := c.checkDeployment(revocation.ChainID, revocation.Contract); err != nil {
+		return err
+	}
+	if revocation.Nonce == "" {
+		return errors.New("session revocation has no nonce")
+	}
+	age := time.Since(time.UnixMilli(revocation.Timestamp))
+	if age > sessionRevocationMaxAge || age < -sessionRevocationMaxAge {
+		return errors.New("session revocation expired")
+	}
+	if err := c.verifyPersonalSignature(ctx, []byte(req.Revocation), req.Signature, revocation.Address); err != nil {
+		return err
+	}
+
+	address := strings.ToLower(revocation.Address)
+	// A nonce never used is recorded as well, so that its token is rejected later
+	if err := c.db.RevokeSession(model.Session{
+		Address:   address,
+		Nonce:     revocation.Nonce,
+		ExpiresAt: time.Now().Add(c.sessionMaxLifetimeOrDefault()),
+	}); err != nil {
+		return errors.Wrap(err, "revoke session")
+	}
+
+	prefix := address + ":" + revocation.Nonce + ":"
+	for key := range c.sessionCache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			c.sessionCache.Delete(key)
+		}
+	}
+	return nil
+}
+
+// sessionMaxLifetimeOrDefault is how long a revoked nonce that was never used is remembered
+func (c *Ctrl) sessionMaxLifetimeOrDefault() time.Duration {
+	if c.sessionMaxLifetime > 0 {
+		return c.sessionMaxLifetime
+	}
+	return 30 * 24 * time.Hour
+}
+
+// PruneSessions forgets the expired sessions
+func (c *Ctrl) PruneSessions() error {
+	return errors.Wrap(c.db.PruneSessions(time.Now()), "prune sessions")
+}
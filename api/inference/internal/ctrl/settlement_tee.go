@@ -3,11 +3,13 @@ package ctrl
 import (
 	"context"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
 	"github.com/0glabs/0g-serving-broker/common/util"
@@ -69,23 +71,32 @@ type SettlementOutcome struct {
 	AdjustedRequest *contract.TEESettlementData // nil if failed completely
 	SettledRequests []*model.Request            // requests that were actually settled
 	UnsettledAmount *big.Int                    // amount that couldn't be settled (for partial)
+	TxHash          common.Hash                 // transaction that settled the requests
 }
 
 // SettlementBatch represents a complete settlement operation
 type SettlementBatch struct {
+	ID              string // identifies the settlement round in the usage records
 	Outcomes        []*SettlementOutcome
 	ExecutableItems []contract.TEESettlementData // items that can be sent to contract
 }
 
 func (c *Ctrl) ProcessSettlement(ctx context.Context) error {
-	settleTriggerThreshold := (c.Service.InputPrice + c.Service.OutputPrice) * constant.SettleTriggerThreshold
+	// Use the most expensive model so that accounts are settled early enough for every service
+	settleTriggerThreshold := util.NewAmount(0)
+	for _, svc := range c.Services {
+		threshold := util.TokenFee(svc.InputPrice.Add(svc.OutputPrice), constant.SettleTriggerThreshold, svc.PriceUnit)
+		if threshold.Cmp(settleTriggerThreshold) > 0 {
+			settleTriggerThreshold = threshold
+		}
+	}
 
 	// Use the optimized method that calculates unsettled fees with a single query
 	accounts, err := c.db.ListUsersWithUnsettledFees(&model.UserListOptions{
 		LowBalanceRisk:         model.PtrOf(time.Now().Add(-c.contract.LockTime + c.autoSettleBufferTime)),
-		MinUnsettledFee:        model.PtrOf(int64(0)),
+		MinUnsettledFee:        model.PtrOf(util.NewAmount(0)),
 		SettleTriggerThreshold: &settleTriggerThreshold,
-	}, c.Service.InputPrice, c.Service.OutputPrice)
+	})
 	if err != nil {
 		return errors.Wrap(err, "list accounts that need to be settled in db")
 	}
@@ -100,10 +111,10 @@ func (c *Ctrl) ProcessSettlement(ctx context.Context) error {
 
 	// Re-check accounts after sync with current time using optimized query
 	accounts, err = c.db.ListUsersWithUnsettledFees(&model.UserListOptions{
-		MinUnsettledFee:        model.PtrOf(int64(0)),
+		MinUnsettledFee:        model.PtrOf(util.NewAmount(0)),
 		LowBalanceRisk:         model.PtrOf(time.Now()),
 		SettleTriggerThreshold: &settleTriggerThreshold,
-	}, c.Service.InputPrice, c.Service.OutputPrice)
+	})
 	if err != nil {
 		return errors.Wrap(err, "list accounts that need to be settled in db after sync")
 	}
@@ -130,6 +141,18 @@ func (c *Ctrl) SettleFeesWithTEE(ctx context.Context) error {
 	if err := c.db.PruneRequest(pruneThreshold); err != nil {
 		c.logger.Infof("Warning: failed to prune old zero-output requests: %v", err)
 	}
+	if err := c.PruneSignatures(); err != nil {
+		c.logger.Infof("Warning: failed to prune expired chat signatures: %v", err)
+	}
+	if err := c.PruneSessions(); err != nil {
+		c.logger.Infof("Warning: failed to prune expired sessions: %v", err)
+	}
+	if err := c.PruneBalanceHolds(); err != nil {
+		c.logger.Infof("Warning: failed to prune expired balance holds: %v", err)
+	}
+	if err := c.PruneUsageRecords(); err != nil {
+		c.logger.Infof("Warning: failed to prune expired usage records: %v", err)
+	}
 
 	// Main settlement loop with limited iterations
 	const maxSettlementRounds = 10
@@ -168,8 +191,8 @@ func (c *Ctrl) SettleFeesWithTEE(ctx context.Context) error {
 			}
 		}
 
-		// Process outcomes (delete/skip requests)
-		c.processOutcomes(batch.Outcomes)
+		// Process outcomes (record/delete/skip requests)
+		c.processOutcomes(batch)
 
 		// If no executable items, we're done
 		if len(batch.ExecutableItems) == 0 {
@@ -264,6 +287,7 @@ func (c *Ctrl) createSettlementBatch(reqs []model.Request) (*SettlementBatch, er
 	}
 
 	return &SettlementBatch{
+		ID:              uuid.NewString(),
 		Outcomes:        outcomes,
 		ExecutableItems: executableItems,
 	}, nil
@@ -377,7 +401,7 @@ func (c *Ctrl) executeAndProcessResults(ctx context.Context, batch *SettlementBa
 	}
 
 	// Execute settlements in contract batches
-	actualFailures, err := c.executeBatches(ctx, batch.ExecutableItems)
+	actualFailures, txHashes, err := c.executeBatches(ctx, batch.ExecutableItems)
 	if err != nil {
 		return errors.Wrap(err, "execute contract batches")
 	}
@@ -393,22 +417,24 @@ func (c *Ctrl) executeAndProcessResults(ctx context.Context, batch *SettlementBa
 			outcome.Status = SettlementPartial // insufficient balance or other failure
 			outcome.AdjustedRequest = nil
 			outcome.SettledRequests = nil
+			continue
 		}
+		outcome.TxHash = txHashes[outcome.User]
 	}
 
 	return nil
 }
 
 // processOutcomes handles the final outcome processing
-func (c *Ctrl) processOutcomes(outcomes []*SettlementOutcome) {
-	for _, outcome := range outcomes {
+func (c *Ctrl) processOutcomes(batch *SettlementBatch) {
+	for _, outcome := range batch.Outcomes {
 		switch outcome.Status {
 		case SettlementSuccess, SettlementPartial:
 			if len(outcome.SettledRequests) > 0 {
-				// Delete successfully settled requests
-				c.deleteRequests(outcome.SettledRequests)
-				c.logger.Infof("User %s: deleted %d settled requests", 
-					outcome.User.Hex(), len(outcome.SettledRequests))
+				// Move successfully settled requests to the usage ledger
+				c.recordSettledRequests(batch.ID, outcome)
+				c.logger.Infof("User %s: recorded %d settled requests in tx %s",
+					outcome.User.Hex(), len(outcome.SettledRequests), outcome.TxHash.Hex())
 			}
 			
 		case SettlementNoSigner:
@@ -432,22 +458,21 @@ func (c *Ctrl) processOutcomes(outcomes []*SettlementOutcome) {
 
 // Helper functions (simplified and consolidated)
 
+// groupRequestsByUser groups the requests by checksummed user address, the requests of an account
+// may carry its address in different cases and must be settled together under one nonce
 func (c *Ctrl) groupRequestsByUser(reqs []model.Request) map[string]*UserRequests {
 	userRequestsMap := make(map[string]*UserRequests)
 	
 	for _, req := range reqs {
-		fee, err := util.ConvertToBigInt(req.Fee)
-		if err != nil {
-			c.logger.Infof("Error parsing fee for request %s: %v", req.RequestHash, err)
-			continue
-		}
+		fee := req.Fee.Big()
+		userAddress := common.HexToAddress(req.UserAddress).Hex()
 
 		reqCopy := req
-		if userReqs, exists := userRequestsMap[req.UserAddress]; exists {
+		if userReqs, exists := userRequestsMap[userAddress]; exists {
 			userReqs.Requests = append(userReqs.Requests, &reqCopy)
 			userReqs.TotalFee = new(big.Int).Add(userReqs.TotalFee, fee)
 		} else {
-			userRequestsMap[req.UserAddress] = &UserRequests{
+			userRequestsMap[userAddress] = &UserRequests{
 				Requests: []*model.Request{&reqCopy},
 				TotalFee: fee,
 			}
@@ -494,10 +519,7 @@ func (c *Ctrl) getRequestsWithinBudget(requests []*model.Request, budget *big.In
 	actualTotalFee := big.NewInt(0)
 	
 	for _, req := range requests {
-		fee, err := util.ConvertToBigInt(req.Fee)
-		if err != nil {
-			continue
-		}
+		fee := req.Fee.Big()
 		
 		if remaining.Cmp(fee) >= 0 {
 			result = append(result, req)
@@ -547,8 +569,43 @@ func (c *Ctrl) deleteRequests(requests []*model.Request) {
 	}
 }
 
-func (c *Ctrl) executeBatches(ctx context.Context, settlements []contract.TEESettlementData) (map[common.Address]SettlementStatus, error) {
+// recordSettledRequests replaces the settled requests of an outcome with their usage records
+func (c *Ctrl) recordSettledRequests(batchID string, outcome *SettlementOutcome) {
+	settledAt := time.Now().UTC()
+	records := make([]model.UsageRecord, len(outcome.SettledRequests))
+	for i, req := range outcome.SettledRequests {
+		records[i] = model.UsageRecord{
+			RequestHash:  req.RequestHash,
+			UserAddress:  req.UserAddress,
+			Nonce:        req.Nonce,
+			ServiceName:  req.ServiceName,
+			InputCount:   req.InputCount,
+			OutputCount:  req.OutputCount,
+			InputPrice:   req.InputPrice,
+			OutputPrice:  req.OutputPrice,
+			PriceUnit:    req.PriceUnit,
+			InputFee:     req.InputFee,
+			OutputFee:    req.OutputFee,
+			Fee:          req.Fee,
+			UsageSource:  req.UsageSource,
+			Truncated:    req.Truncated,
+			APIKeyID:     req.APIKeyID,
+			SessionNonce: req.SessionNonce,
+			RequestedAt:  req.CreatedAt,
+			SettledAt:    settledAt,
+			TxHash:       outcome.TxHash.Hex(),
+			BatchID:      batchID,
+		}
+	}
+
+	if err := c.db.SettleRequests(records); err != nil {
+		c.logger.Infof("Error recording settled requests: %v", err)
+	}
+}
+
+func (c *Ctrl) executeBatches(ctx context.Context, settlements []contract.TEESettlementData) (map[common.Address]SettlementStatus, map[common.Address]common.Hash, error) {
 	failures := make(map[common.Address]SettlementStatus)
+	txHashes := make(map[common.Address]common.Hash)
 	
 	// Process in batches
 	for i := 0; i < len(settlements); i += constant.TEESettlementBatchSize {
@@ -560,17 +617,20 @@ func (c *Ctrl) executeBatches(ctx context.Context, settlements []contract.TEESet
 		batch := settlements[i:end]
 		c.logger.Infof("Executing settlement batch %d-%d", i+1, end)
 		
-		failedUsers, err := c.contract.SettleFeesWithTEE(ctx, batch)
+		txHash, failedUsers, err := c.contract.SettleFeesWithTEE(ctx, batch)
 		if err != nil {
-			return failures, errors.Wrapf(err, "settlement batch %d-%d failed", i, end-1)
+			return failures, txHashes, errors.Wrapf(err, "settlement batch %d-%d failed", i, end-1)
 		}
 		
+		for _, settlement := range batch {
+			txHashes[settlement.User] = txHash
+		}
 		for _, user := range failedUsers {
 			failures[user] = SettlementPartial
 		}
 	}
 	
-	return failures, nil
+	return failures, txHashes, nil
 }
 
 // getUserRequestsForAddress gets all unprocessed requests for a specific user
@@ -590,16 +650,11 @@ func (c *Ctrl) getUserRequestsForAddress(userAddress string) (*UserRequests, err
 	totalFee := big.NewInt(0)
 	
 	for _, req := range reqs {
-		if req.UserAddress == userAddress {
+		if strings.EqualFold(req.UserAddress, userAddress) {
 			reqCopy := req
 			userRequests = append(userRequests, &reqCopy)
 			
-			fee, err := util.ConvertToBigInt(req.Fee)
-			if err != nil {
-				c.logger.Infof("Error parsing fee for request %s: %v", req.RequestHash, err)
-				continue
-			}
-			totalFee.Add(totalFee, fee)
+			totalFee.Add(totalFee, req.Fee.Big())
 		}
 	}
 
@@ -620,9 +675,9 @@ func (c *Ctrl) hashUserRequests(requests []*model.Request) [32]byte {
 	for _, req := range requests {
 		requestData = append(requestData, []byte(req.RequestHash)...)
 		requestData = append(requestData, []byte(req.UserAddress)...)
-		requestData = append(requestData, []byte(req.Fee)...)
-		requestData = append(requestData, []byte(req.InputFee)...)
-		requestData = append(requestData, []byte(req.OutputFee)...)
+		requestData = append(requestData, []byte(req.Fee.String())...)
+		requestData = append(requestData, []byte(req.InputFee.String())...)
+		requestData = append(requestData, []byte(req.OutputFee.String())...)
 	}
 	return crypto.Keccak256Hash(requestData)
 }
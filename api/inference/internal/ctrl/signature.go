@@ -0,0 +1,79 @@
+package ctrl
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/internal/db"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// SignatureStore persists response signatures so that they survive restarts and are shared by
+// every replica of the broker. It is implemented by the MySQL backed db.DB
+type SignatureStore interface {
+	PutSignature(sig model.ChatSignature) error
+	GetSignatureByChatID(chatID string) (model.ChatSignature, error)
+	GetSignatureByRequestHash(requestHash string) (model.ChatSignature, error)
+	PruneSignatures(retention time.Duration) error
+}
+
+var _ SignatureStore = (*db.DB)(nil)
+
+// GetChatSignature looks the signature up in the local cache first, then in the store
+func (c *Ctrl) GetChatSignature(chatID string) (*ChatSignature, error) {
+	key := c.chatCacheKey(chatID)
+	c.logger.Debugf("get signature for chat: %v", chatID)
+	if val, exist := c.svcCache.Get(key); exist {
+		if chatSignature, ok := val.(ChatSignature); ok {
+			return &chatSignature, nil
+		}
+	}
+
+	sig, err := c.signatures.GetSignatureByChatID(chatID)
+	if err != nil {
+		if db.IgnoreNotFound(err) == nil {
+			return nil, errors.New("Chat id not found or expired, chat_id_not_found")
+		}
+		return nil, errors.Wrap(err, "get chat signature")
+	}
+	return toChatSignature(sig)
+}
+
+func (c *Ctrl) GetChatSignatureByRequestHash(requestHash string) (*ChatSignature, error) {
+	c.logger.Debugf("get signature for request: %v", requestHash)
+	sig, err := c.signatures.GetSignatureByRequestHash(requestHash)
+	if err != nil {
+		if db.IgnoreNotFound(err) == nil {
+			return nil, errors.New("Request hash not found or expired, request_hash_not_found")
+		}
+		return nil, errors.Wrap(err, "get chat signature")
+	}
+	return toChatSignature(sig)
+}
+
+func (c *Ctrl) PruneSignatures() error {
+	return errors.Wrap(c.signatures.PruneSignatures(c.signatureRetention), "prune chat signatures")
+}
+
+func toChatSignature(sig model.ChatSignature) (*ChatSignature, error) {
+	chatSignature := &ChatSignature{
+		Text:                sig.Text,
+		SignatureEcdsa:      sig.Signature,
+		SigningAddressEcdsa: common.HexToAddress(sig.SigningAddress),
+		SigningAlgo:         sig.SigningAlgo,
+		RequestHash:         sig.RequestHash,
+		PromptTokens:        sig.InputCount,
+		CompletionTokens:    sig.OutputCount,
+		Fee:                 sig.Fee,
+	}
+	if sig.SigningAlgo == EIP712.String() {
+		domain, err := typedDataDomain(sig.Text)
+		if err != nil {
+			return nil, err
+		}
+		chatSignature.Domain = domain
+	}
+	return chatSignature, nil
+}
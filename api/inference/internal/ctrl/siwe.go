@@ -0,0 +1,312 @@
+package ctrl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+const (
+	// SessionIssuerSIWE marks the session tokens issued by the broker after a SIWE login, they are
+	// signed by the broker instead of the user
+	SessionIssuerSIWE = "siwe"
+
+	siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+	siweVersion      = "1"
+	// siweNonceLifetime bounds the time between asking a nonce and logging in
+	siweNonceLifetime = 5 * time.Minute
+	// siweProviderResourcePrefix prefixes the provider address in the resources of the message
+	siweProviderResourcePrefix = "urn:0g:provider:"
+)
+
+// SIWEMessage is an EIP-4361 message, see https://eips.ethereum.org/EIPS/eip-4361
+type SIWEMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// SIWELoginRequest carries the message as it was signed
+type SIWELoginRequest struct {
+	Message   string `json:"message" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+// SIWELoginResponse is the session credential, sent as the Address, Session-Token and
+// Session-Signature headers like a session signed by the user
+type SIWELoginResponse struct {
+	Address          string `json:"address"`
+	SessionToken     string `json:"sessionToken"`
+	SessionSignature string `json:"sessionSignature"`
+	ExpiresAt        int64  `json:"expiresAt"`
+}
+
+// ParseSIWEMessage parses the text of an EIP-4361 message
+func ParseSIWEMessage(text string) (SIWEMessage, error) {
+	var msg SIWEMessage
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) < 2 || !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return msg, errors.New("invalid SIWE message header")
+	}
+	msg.Domain = strings.TrimSuffix(lines[0], siweHeaderSuffix)
+	msg.Address = strings.TrimSpace(lines[1])
+	if !common.IsHexAddress(msg.Address) {
+		return msg, errors.New("invalid SIWE message address")
+	}
+
+	var statement []string
+	inResources := false
+	for _, line := range lines[2:] {
+		if inResources {
+			if resource, ok := strings.CutPrefix(line, "- "); ok {
+				msg.Resources = append(msg.Resources, resource)
+				continue
+			}
+			inResources = false
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			if line == "Resources:" {
+				inResources = true
+			} else if line != "" && msg.URI == "" {
+				statement = append(statement, line)
+			}
+			continue
+		}
+
+		var err error
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			msg.ChainID, err = strconv.ParseInt(value, 10, 64)
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			msg.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			msg.ExpirationTime = &t
+		case "Not Before":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			msg.NotBefore = &t
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			if msg.URI == "" {
+				statement = append(statement, line)
+			}
+		}
+		if err != nil {
+			return msg, errors.Wrapf(err, "invalid SIWE message field %s", key)
+		}
+	}
+	msg.Statement = strings.Join(statement, "\n")
+
+	if msg.URI == "" || msg.Version == "" || msg.Nonce == "" || msg.IssuedAt.IsZero() {
+		return msg, errors.New("SIWE message misses a required field")
+	}
+	return msg, nil
+}
+
+// siweNonceKey authenticates the nonces issued by the broker, it is derived from the broker signer
+// so that every replica sharing the signer accepts them
+func (c *Ctrl) siweNonceKey() []byte {
+	return crypto.Keccak256(crypto.FromECDSA(c.teeService.ProviderSigner), []byte("siwe-nonce"))
+}
+
+// SIWENonce issues a nonce for a SIWE login: 8 random bytes, the expiry and a MAC, hex encoded
+func (c *Ctrl) SIWENonce() (string, error) {
+	payload := make([]byte, 12)
+	if _, err := rand.Read(payload[:8]); err != nil {
+		return "", errors.Wrap(err, "generate nonce")
+	}
+	binary.BigEndian.PutUint32(payload[8:], uint32(time.Now().Add(siweNonceLifetime).Unix()))
+	mac := hmac.New(sha256.New, c.siweNonceKey())
+	mac.Write(payload)
+	return hex.EncodeToString(append(payload, mac.Sum(nil)[:8]...)), nil
+}
+
+func (c *Ctrl) checkSIWENonce(nonce string) error {
+	raw, err := hex.DecodeString(nonce)
+	if err != nil || len(raw) != 20 {
+		return errors.New("SIWE nonce was not issued by this provider")
+	}
+	mac := hmac.New(sha256.New, c.siweNonceKey())
+	mac.Write(raw[:12])
+	if !hmac.Equal(raw[12:], mac.Sum(nil)[:8]) {
+		return errors.New("SIWE nonce was not issued by this provider")
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint32(raw[8:12])) {
+		return errors.New("SIWE nonce expired")
+	}
+	return nil
+}
+
+// LoginWithSIWE checks a signed SIWE message and issues a session token signed by the broker.
+// The nonce can be used once, and the session can be revoked like a session signed by the user
+func (c *Ctrl) LoginWithSIWE(ctx context.Context, req SIWELoginRequest) (SIWELoginResponse, error) {
+	msg, err := ParseSIWEMessage(req.Message)
+	if err != nil {
+		return SIWELoginResponse{}, err
+	}
+	if err := c.checkSIWEMessage(msg); err != nil {
+		return SIWELoginResponse{}, err
+	}
+
+	sig, err := hexutil.Decode(req.Signature)
+	if err != nil {
+		return SIWELoginResponse{}, errors.Wrap(err, "invalid signature format")
+	}
+	address := common.HexToAddress(msg.Address)
+	if _, err := c.contract.SignatureVerifier.Verify(ctx, accounts.TextHash([]byte(req.Message)), sig, address); err != nil {
+		return SIWELoginResponse{}, errors.Wrap(err, "SIWE signature verification failed")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.siweCredentialLifetime)
+	if msg.ExpirationTime.Before(expiresAt) {
+		expiresAt = *msg.ExpirationTime
+	}
+	token := SessionToken{
+		Address:   address.Hex(),
+		Provider:  c.contract.ProviderAddress,
+		Timestamp: now.UnixMilli(),
+		ExpiresAt: expiresAt.UnixMilli(),
+		Nonce:     msg.Nonce,
+		ChainID:   msg.ChainID,
+		Contract:  c.contract.ContractAddress.Hex(),
+		Issuer:    SessionIssuerSIWE,
+	}
+	tokenStr, err := json.Marshal(token)
+	if err != nil {
+		return SIWELoginResponse{}, errors.Wrap(err, "marshal session token")
+	}
+	if err := c.checkSessionToken(token); err != nil {
+		return SIWELoginResponse{}, err
+	}
+
+	// The nonce is recorded with the token, so that a replayed login fails
+	if err := c.db.CreateSession(model.Session{
+		Address:   strings.ToLower(token.Address),
+		Nonce:     token.Nonce,
+		TokenHash: crypto.Keccak256Hash(tokenStr).Hex(),
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return SIWELoginResponse{}, errors.Wrap(err, "record SIWE session, a nonce can be used once")
+	}
+
+	prefixedMsg := crypto.Keccak256Hash([]byte("\x19Ethereum Signed Message:\n32"), crypto.Keccak256(tokenStr))
+	tokenSig, err := crypto.Sign(prefixedMsg.Bytes(), c.teeService.ProviderSigner)
+	if err != nil {
+		return SIWELoginResponse{}, errors.Wrap(err, "sign session token")
+	}
+	tokenSig[64] += 27
+
+	return SIWELoginResponse{
+		Address:          token.Address,
+		SessionToken:     string(tokenStr),
+		SessionSignature: hexutil.Encode(tokenSig),
+		ExpiresAt:        token.ExpiresAt,
+	}, nil
+}
+
+// siweDomainOf returns the domain expected in the SIWE messages, the configured domain or else the
+// host of the serving URL of the primary service. It is never taken from the login request, whose
+// Host header is chosen by the caller
+func siweDomainOf(cfg *config.Config) string {
+	if cfg.SIWE.Domain != "" {
+		return cfg.SIWE.Domain
+	}
+	if len(cfg.Services) == 0 {
+		return ""
+	}
+	servingURL, err := url.Parse(cfg.Services[0].ServingURL)
+	if err != nil {
+		return ""
+	}
+	return servingURL.Host
+}
+
+func (c *Ctrl) checkSIWEMessage(msg SIWEMessage) error {
+	if c.siweDomain == "" {
+		return errors.New("SIWE login is disabled, configure siwe.domain or a servingUrl with a host")
+	}
+	if !strings.EqualFold(msg.Domain, c.siweDomain) {
+		return fmt.Errorf("SIWE message is for domain %s, expected %s", msg.Domain, c.siweDomain)
+	}
+	if msg.Version != siweVersion {
+		return fmt.Errorf("SIWE message version %s is not supported", msg.Version)
+	}
+	if err := c.checkDeployment(msg.ChainID, c.contract.ContractAddress.Hex()); err != nil {
+		return err
+	}
+	if err := c.checkSIWENonce(msg.Nonce); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if age := now.Sub(msg.IssuedAt); age > siweNonceLifetime || age < -siweNonceLifetime {
+		return errors.New("SIWE message issued too long ago")
+	}
+	if msg.ExpirationTime == nil {
+		return errors.New("SIWE message has no expiration time")
+	}
+	if !msg.ExpirationTime.After(now) {
+		return errors.New("SIWE message expired")
+	}
+	if msg.NotBefore != nil && now.Before(*msg.NotBefore) {
+		return errors.New("SIWE message is not valid yet")
+	}
+
+	provider := siweProviderResourcePrefix + c.contract.ProviderAddress
+	for _, resource := range msg.Resources {
+		if strings.EqualFold(resource, provider) {
+			return nil
+		}
+	}
+	return fmt.Errorf("SIWE message resources do not include %s", provider)
+}
+
+// sessionSigner is the address expected to sign a session token
+func (c *Ctrl) sessionSigner(token SessionToken, address string) (string, error) {
+	switch token.Issuer {
+	case "":
+		return address, nil
+	case SessionIssuerSIWE:
+		return c.teeService.Address.Hex(), nil
+	default:
+		return "", fmt.Errorf("session token issuer %s is not supported", token.Issuer)
+	}
+}
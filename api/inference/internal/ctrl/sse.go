@@ -0,0 +1,138 @@
+package ctrl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+var sseDoneData = []byte("[DONE]")
+
+// sseEvent is one dispatched server-sent event
+type sseEvent struct {
+	Event string
+	Data  []byte
+}
+
+// sseParser incrementally decodes a text/event-stream, holding at most one event in memory
+type sseParser struct {
+	reader *bufio.Reader
+}
+
+func newSSEParser(r io.Reader) *sseParser {
+	return &sseParser{reader: bufio.NewReader(r)}
+}
+
+// Next returns the next event with data, or io.EOF once the stream is exhausted
+func (p *sseParser) Next() (*sseEvent, error) {
+	var event sseEvent
+	var data bytes.Buffer
+	hasData := false
+
+	for {
+		line, err := p.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		eof := err == io.EOF
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			// A blank line dispatches the event, an unterminated last event is dispatched at EOF
+			if hasData {
+				event.Data = data.Bytes()
+				return &event, nil
+			}
+			if eof {
+				return nil, io.EOF
+			}
+			event.Event = ""
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "":
+			// Comment line, e.g. a keep-alive
+		case "event":
+			event.Event = string(value)
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		}
+
+		if eof {
+			if hasData {
+				event.Data = data.Bytes()
+				return &event, nil
+			}
+			return nil, io.EOF
+		}
+	}
+}
+
+// streamTracker accumulates what is billed from a stream while its events pass through
+type streamTracker struct {
+	chatID string
+	usage  *Usage
+	output strings.Builder
+	done   bool
+}
+
+func (t *streamTracker) track(handler *routeHandler, event *sseEvent) error {
+	// Only the default message events carry completion chunks
+	if t.done || (event.Event != "" && event.Event != "message") {
+		return nil
+	}
+	if bytes.Equal(event.Data, sseDoneData) {
+		t.done = true
+		return nil
+	}
+
+	usage, output, err := handler.extractUsage(event.Data)
+	if err != nil {
+		return err
+	}
+	// For stream responses, usage info comes in the last chunk before [DONE]
+	if usage != nil {
+		t.usage = usage
+	}
+	t.output.WriteString(output)
+
+	if t.chatID == "" {
+		var chunk struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(event.Data, &chunk); err == nil {
+			t.chatID = chunk.ID
+		}
+	}
+	return nil
+}
+
+// passThroughReader forwards every chunk read from the upstream to the client before the
+// chunk is decoded, so the client never waits for the broker's own processing
+type passThroughReader struct {
+	src      io.Reader
+	dst      io.Writer
+	flush    func()
+	writeErr error
+}
+
+func (r *passThroughReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	if n > 0 {
+		if _, werr := r.dst.Write(p[:n]); werr != nil {
+			r.writeErr = werr
+			return n, werr
+		}
+		r.flush()
+	}
+	return n, err
+}
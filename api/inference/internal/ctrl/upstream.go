@@ -0,0 +1,223 @@
+package ctrl
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/config"
+	"github.com/0glabs/0g-serving-broker/inference/monitor"
+)
+
+// Defaults of config.Transport
+const (
+	defaultDialTimeout           = 10 * time.Second
+	defaultResponseHeaderTimeout = 10 * time.Minute
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultMaxIdleConnsPerHost   = 100
+	defaultRetryBackoff          = 200 * time.Millisecond
+	// h2cPingInterval is how long an h2c connection may stay silent before it is health checked
+	h2cPingInterval = 30 * time.Second
+)
+
+// upstreamClient is the pooled client shared by every request to the backend of a service
+type upstreamClient struct {
+	model         string
+	client        *http.Client
+	headerTimeout time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+}
+
+func newUpstreamClient(svc config.Service) (*upstreamClient, error) {
+	t := svc.Transport
+	u := &upstreamClient{
+		model:         svc.ModelType,
+		headerTimeout: durationOrDefault(t.ResponseHeaderTimeout, defaultResponseHeaderTimeout),
+		maxRetries:    t.MaxRetries,
+		retryBackoff:  durationOrDefault(t.RetryBackoff, defaultRetryBackoff),
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(t.DialTimeout, defaultDialTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return u.trackConn(conn), nil
+	}
+
+	maxIdleConnsPerHost := t.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost == 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	idleConnTimeout := durationOrDefault(t.IdleConnTimeout, defaultIdleConnTimeout)
+
+	var transport http.RoundTripper
+	switch strings.ToLower(t.Protocol) {
+	case "", "http1", "http2":
+		transport = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dial,
+			MaxConnsPerHost:     t.MaxConnsPerHost,
+			MaxIdleConns:        maxIdleConnsPerHost,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			IdleConnTimeout:     idleConnTimeout,
+			TLSHandshakeTimeout: dialer.Timeout,
+			// HTTP/2 is only negotiated with TLS backends
+			ForceAttemptHTTP2: strings.ToLower(t.Protocol) == "http2",
+		}
+	case "h2c":
+		transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+			IdleConnTimeout: idleConnTimeout,
+			ReadIdleTimeout: h2cPingInterval,
+		}
+	default:
+		return nil, fmt.Errorf("transport protocol %s of model %s is not supported", t.Protocol, svc.ModelType)
+	}
+
+	u.client = &http.Client{Transport: transport}
+	return u, nil
+}
+
+// Do sends the request, retrying a retryable one on connection errors and on 502, 503 and 504
+// responses. Nothing has reached the client yet when a retry happens, so it is invisible to it.
+// The request body is replayed from body on every attempt
+func (u *upstreamClient) Do(req *http.Request, body []byte, retryable bool) (*http.Response, error) {
+	attempts := 1
+	if retryable {
+		attempts += u.maxRetries
+	}
+
+	for i := 1; ; i++ {
+		attempt := req.Clone(req.Context())
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		attempt.ContentLength = int64(len(body))
+
+		resp, err := u.roundTrip(attempt)
+		if i >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil {
+			if !retryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if monitor.UpstreamRetryCount != nil {
+			monitor.UpstreamRetryCount.WithLabelValues(u.model).Inc()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(u.retryBackoff * time.Duration(i)):
+		}
+	}
+}
+
+// roundTrip sends one attempt and cancels it when the response headers take longer than the
+// header timeout. The attempt stays in flight until its response body is closed
+func (u *upstreamClient) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if monitor.UpstreamConnReuse != nil {
+				monitor.UpstreamConnReuse.WithLabelValues(u.model, strconv.FormatBool(info.Reused)).Inc()
+			}
+		},
+	})
+	if monitor.UpstreamInFlight != nil {
+		monitor.UpstreamInFlight.WithLabelValues(u.model).Inc()
+	}
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			cancel()
+			if monitor.UpstreamInFlight != nil {
+				monitor.UpstreamInFlight.WithLabelValues(u.model).Dec()
+			}
+		})
+	}
+
+	timer := time.AfterFunc(u.headerTimeout, cancel)
+	resp, err := u.client.Do(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		done()
+		return nil, errors.Wrapf(context.DeadlineExceeded, "backend did not respond within %s", u.headerTimeout)
+	}
+	if err != nil {
+		done()
+		return nil, err
+	}
+	resp.Body = &upstreamBody{ReadCloser: resp.Body, done: done}
+	return resp, nil
+}
+
+func (u *upstreamClient) trackConn(conn net.Conn) net.Conn {
+	if monitor.UpstreamConnections == nil {
+		return conn
+	}
+	monitor.UpstreamConnections.WithLabelValues(u.model).Inc()
+	return &trackedConn{Conn: conn, closed: func() {
+		monitor.UpstreamConnections.WithLabelValues(u.model).Dec()
+	}}
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// upstreamBody releases the attempt once the response is consumed
+type upstreamBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *upstreamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+// trackedConn reports its closing to the pool metrics
+type trackedConn struct {
+	net.Conn
+	once   sync.Once
+	closed func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.closed)
+	return c.Conn.Close()
+}
@@ -0,0 +1,19 @@
+package ctrl
+
+import (
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+func (c *Ctrl) ListUsageRecord(q model.UsageRecordListOptions) (model.UsageRecordList, error) {
+	list, err := c.db.ListUsageRecord(q)
+	if err != nil {
+		return list, errors.Wrap(err, "list usage record from db")
+	}
+	return list, nil
+}
+
+// PruneUsageRecords forgets the usage records older than the usage retention period
+func (c *Ctrl) PruneUsageRecords() error {
+	return errors.Wrap(c.db.PruneUsageRecords(c.usageRetention), "prune usage records")
+}
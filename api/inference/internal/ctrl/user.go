@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/common/util"
 	"github.com/0glabs/0g-serving-broker/inference/contract"
 	"github.com/0glabs/0g-serving-broker/inference/internal/db"
 	"github.com/0glabs/0g-serving-broker/inference/model"
@@ -32,7 +33,7 @@ func (c *Ctrl) GetOrCreateAccount(ctx context.Context, userAddress string) (mode
 
 	dbAccount = model.User{
 		User:                 userAddress,
-		LockBalance:          model.PtrOf(lockBalance.String()),
+		LockBalance:          model.PtrOf(util.NewAmountFromBig(lockBalance)),
 		LastBalanceCheckTime: model.PtrOf(time.Now().UTC()),
 		Signer:               []string{contractAccount.Signer[0].String(), contractAccount.Signer[1].String()},
 	}
@@ -97,7 +98,7 @@ func (c *Ctrl) SyncUserAccount(ctx context.Context, userAddress common.Address)
 	lockBalance.Sub(account.Balance, account.PendingRefund)
 
 	new := model.User{
-		LockBalance:          model.PtrOf(lockBalance.String()),
+		LockBalance:          model.PtrOf(util.NewAmountFromBig(lockBalance)),
 		LastBalanceCheckTime: model.PtrOf(time.Now().UTC()),
 		Signer:               []string{account.Signer[0].String(), account.Signer[1].String()},
 	}
@@ -121,7 +122,7 @@ func parse(account contract.Account) model.User {
 
 	return model.User{
 		User:                 account.User.String(),
-		LockBalance:          model.PtrOf(lockBalance.String()),
+		LockBalance:          model.PtrOf(util.NewAmountFromBig(lockBalance)),
 		LastBalanceCheckTime: model.PtrOf(time.Now().UTC()),
 		Signer:               []string{account.Signer[0].String(), account.Signer[1].String()},
 	}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+func (d *DB) CreateAPIKey(key model.APIKey) error {
+	return d.db.Create(&key).Error
+}
+
+func (d *DB) GetAPIKey(id string) (model.APIKey, error) {
+	key := model.APIKey{}
+	ret := d.db.Where(&model.APIKey{ID: id}).First(&key)
+	return key, ret.Error
+}
+
+func (d *DB) GetAPIKeyByHash(keyHash string) (model.APIKey, error) {
+	key := model.APIKey{}
+	ret := d.db.Where(&model.APIKey{KeyHash: keyHash}).First(&key)
+	return key, ret.Error
+}
+
+func (d *DB) ListAPIKey(userAddress string) ([]model.APIKey, error) {
+	list := []model.APIKey{}
+	ret := d.db.Where(&model.APIKey{User: userAddress}).Order("created_at DESC").Find(&list)
+	return list, ret.Error
+}
+
+// RevokeAPIKey revokes the key matching the non-zero fields of key
+func (d *DB) RevokeAPIKey(key model.APIKey) error {
+	revoked := true
+	ret := d.db.Where(&key).Updates(&model.APIKey{Revoked: &revoked})
+	if ret.Error != nil {
+		return ret.Error
+	}
+	if ret.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// addAPIKeySpend adds a billed fee to the spending of a key, in one statement so that concurrent
+// requests do not lose updates
+func addAPIKeySpend(tx *gorm.DB, id string, fee util.Amount) error {
+	return tx.Model(&model.APIKey{}).
+		Where("id = ?", id).
+		Update("spent", gorm.Expr("spent + CAST(? AS DECIMAL(65,0))", fee)).Error
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+var (
+	// ErrInsufficientBalance is returned when a hold does not fit in the locked balance of the user
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrSpendingCapReached is returned when a hold does not fit in the spending cap of its session
+	// or of its API key
+	ErrSpendingCapReached = errors.New("spending cap reached")
+)
+
+// PlaceHold reserves hold.Amount for a request if the locked balance of the user covers it on top
+// of the unsettled fees and the holds of the other requests in flight, and if sessionCap, the
+// spending cap of the session of the request when it has one, covers it on top of the spending
+// of the session and the holds of its other requests. The spending cap of the API key of the
+// request is checked the same way. The user row is locked for the checks, so that concurrent
+// requests of a user, whose keys and sessions are all its own, are checked one after the other
+// and see each other's holds.
+// The total required is returned with ErrInsufficientBalance
+func (d *DB) PlaceHold(hold model.BalanceHold, sessionCap *util.Amount) (util.Amount, error) {
+	var required util.Amount
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		account := model.User{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user = ?", hold.User).
+			First(&account).Error; err != nil {
+			return err
+		}
+
+		// The reads below start after the lock is held, they see the holds committed by the
+		// requests checked before
+		var unsettledFee, held util.Amount
+		if err := tx.Model(&model.Request{}).
+			Select("COALESCE(SUM(fee), 0)").
+			Where("user_address = ? AND processed = ?", hold.User, false).
+			Scan(&unsettledFee).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.BalanceHold{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("user = ? AND expires_at > ?", hold.User, time.Now()).
+			Scan(&held).Error; err != nil {
+			return err
+		}
+
+		required = unsettledFee.Add(held).Add(hold.Amount)
+		if account.LockBalance == nil || required.Cmp(*account.LockBalance) > 0 {
+			return ErrInsufficientBalance
+		}
+		if hold.SessionNonce != "" && sessionCap != nil {
+			if err := checkSessionCap(tx, hold, *sessionCap); err != nil {
+				return err
+			}
+		}
+		if hold.APIKeyID != "" {
+			if err := checkAPIKeyCap(tx, hold); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&hold).Error
+	})
+	return required, err
+}
+
+// checkSessionCap rejects a hold that does not fit in the spending cap of its session, on top of
+// the fees billed to the session and the holds of its other requests in flight. The fee of a
+// request is added to the session in the transaction deleting its hold, so it is counted once
+func checkSessionCap(tx *gorm.DB, hold model.BalanceHold, sessionCap util.Amount) error {
+	session := model.Session{}
+	if err := tx.Where("address = ? AND nonce = ?", strings.ToLower(hold.User), hold.SessionNonce).
+		First(&session).Error; err != nil {
+		return err
+	}
+	held, err := sessionHeld(tx, hold.User, hold.SessionNonce, hold.RequestHash)
+	if err != nil {
+		return err
+	}
+	if session.Spent.Add(held).Add(hold.Amount).Cmp(sessionCap) > 0 {
+		return errors.Wrapf(ErrSpendingCapReached, "session spending cap of %s, %s spent and %s held by the requests in flight",
+			sessionCap, session.Spent, held)
+	}
+	return nil
+}
+
+// checkAPIKeyCap rejects a hold that does not fit in the spending cap of its API key, on top of
+// the fees billed to the key and the holds of its other requests in flight
+func checkAPIKeyCap(tx *gorm.DB, hold model.BalanceHold) error {
+	key := model.APIKey{}
+	if err := tx.Where("id = ?", hold.APIKeyID).First(&key).Error; err != nil {
+		return err
+	}
+	if key.SpendingCap == nil {
+		return nil
+	}
+	held, err := apiKeyHeld(tx, hold.APIKeyID, hold.RequestHash)
+	if err != nil {
+		return err
+	}
+	if key.Spent.Add(held).Add(hold.Amount).Cmp(*key.SpendingCap) > 0 {
+		return errors.Wrapf(ErrSpendingCapReached, "API key spending cap of %s, %s spent and %s held by the requests in flight",
+			key.SpendingCap, key.Spent, held)
+	}
+	return nil
+}
+
+// SessionHeld returns the sum of the holds of the requests of a session in flight, other than
+// requestHash
+func (d *DB) SessionHeld(user, sessionNonce, requestHash string) (util.Amount, error) {
+	return sessionHeld(d.db, user, sessionNonce, requestHash)
+}
+
+func sessionHeld(tx *gorm.DB, user, sessionNonce, requestHash string) (util.Amount, error) {
+	var held util.Amount
+	err := tx.Model(&model.BalanceHold{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user = ? AND session_nonce = ? AND request_hash != ? AND expires_at > ?", user, sessionNonce, requestHash, time.Now()).
+		Scan(&held).Error
+	return held, err
+}
+
+// APIKeyHeld returns the sum of the holds of the requests of an API key in flight, other than
+// requestHash
+func (d *DB) APIKeyHeld(apiKeyID, requestHash string) (util.Amount, error) {
+	return apiKeyHeld(d.db, apiKeyID, requestHash)
+}
+
+func apiKeyHeld(tx *gorm.DB, apiKeyID, requestHash string) (util.Amount, error) {
+	var held util.Amount
+	err := tx.Model(&model.BalanceHold{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("api_key_id = ? AND request_hash != ? AND expires_at > ?", apiKeyID, requestHash, time.Now()).
+		Scan(&held).Error
+	return held, err
+}
+
+// AvailableBalance returns the locked balance of a user minus the unsettled fees and the holds of
+// the requests in flight other than requestHash
+func (d *DB) AvailableBalance(user, requestHash string) (util.Amount, error) {
+	account := model.User{}
+	if err := d.db.Where("user = ?", user).First(&account).Error; err != nil {
+		return util.Amount{}, err
+	}
+	var unsettledFee, held util.Amount
+	if err := d.db.Model(&model.Request{}).
+		Select("COALESCE(SUM(fee), 0)").
+		Where("user_address = ? AND processed = ?", user, false).
+		Scan(&unsettledFee).Error; err != nil {
+		return util.Amount{}, err
+	}
+	if err := d.db.Model(&model.BalanceHold{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user = ? AND request_hash != ? AND expires_at > ?", user, requestHash, time.Now()).
+		Scan(&held).Error; err != nil {
+		return util.Amount{}, err
+	}
+	if account.LockBalance == nil {
+		return util.NewAmount(0).Sub(unsettledFee).Sub(held), nil
+	}
+	return account.LockBalance.Sub(unsettledFee).Sub(held), nil
+}
+
+// ReleaseHold deletes the hold of a request, it is a no-op once the hold is released
+func (d *DB) ReleaseHold(requestHash string) error {
+	return d.db.Where("request_hash = ?", requestHash).Delete(&model.BalanceHold{}).Error
+}
+
+// PruneHolds deletes the holds expired before the cutoff, they are not counted anyway
+func (d *DB) PruneHolds(cutoff time.Time) error {
+	return d.db.Where("expires_at <= ?", cutoff).Delete(&model.BalanceHold{}).Error
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"time"
 
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 	"github.com/go-gormigrate/gormigrate/v2"
 	"github.com/pkg/errors"
@@ -10,7 +12,9 @@ import (
 	"gorm.io/plugin/soft_delete"
 )
 
-func (d *DB) Migrate() error {
+// Migrate applies the schema migrations, services prices the requests recorded before prices were
+// snapshotted on them
+func (d *DB) Migrate(services []config.Service) error {
 	d.db.Set("gorm:table_options", "ENGINE=InnoDB")
 
 	m := gormigrate.New(d.db, &gormigrate.Options{UseTransaction: false}, []*gormigrate.Migration{
@@ -138,6 +142,257 @@ func (d *DB) Migrate() error {
 				return nil
 			},
 		},
+		{
+			ID: "add-usage-source-to-request",
+			Migrate: func(tx *gorm.DB) error {
+				type Request struct {
+					UsageSource string `gorm:"type:varchar(32);not null;default:''"`
+				}
+				return tx.AutoMigrate(&Request{})
+			},
+		},
+		{
+			ID: "add-truncated-to-request",
+			Migrate: func(tx *gorm.DB) error {
+				type Request struct {
+					Truncated *bool `gorm:"type:tinyint(1);not null;default:0"`
+				}
+				return tx.AutoMigrate(&Request{})
+			},
+		},
+		{
+			ID: "create-chat-signature",
+			Migrate: func(tx *gorm.DB) error {
+				type ChatSignature struct {
+					model.Model
+					ChatID         string `gorm:"type:varchar(255);not null;primaryKey"`
+					RequestHash    string `gorm:"type:varchar(255);not null;index"`
+					Text           string `gorm:"type:text;not null"`
+					Signature      string `gorm:"type:varchar(255);not null"`
+					SigningAddress string `gorm:"type:varchar(255);not null"`
+					SigningAlgo    string `gorm:"type:varchar(32);not null"`
+				}
+				return tx.AutoMigrate(&ChatSignature{})
+			},
+		},
+		{
+			ID: "add-usage-and-fee-to-chat-signature",
+			Migrate: func(tx *gorm.DB) error {
+				type ChatSignature struct {
+					InputCount  int64  `gorm:"type:bigint;not null;default:0"`
+					OutputCount int64  `gorm:"type:bigint;not null;default:0"`
+					Fee         string `gorm:"type:varchar(255);not null;default:'0'"`
+				}
+				return tx.AutoMigrate(&ChatSignature{})
+			},
+		},
+		{
+			ID: "create-user-rate-limit",
+			Migrate: func(tx *gorm.DB) error {
+				type UserRateLimit struct {
+					model.Model
+					User                 string `gorm:"type:varchar(255);not null;primaryKey"`
+					RequestsPerMinute    *int64 `gorm:"type:bigint"`
+					TokensPerMinute      *int64 `gorm:"type:bigint"`
+					MaxConcurrentStreams *int64 `gorm:"type:bigint"`
+				}
+				return tx.AutoMigrate(&UserRateLimit{})
+			},
+		},
+		{
+			ID: "create-session",
+			Migrate: func(tx *gorm.DB) error {
+				type Session struct {
+					model.Model
+					Address   string    `gorm:"type:varchar(255);not null;primaryKey"`
+					Nonce     string    `gorm:"type:varchar(255);not null;primaryKey"`
+					TokenHash string    `gorm:"type:varchar(66);not null;default:''"`
+					ExpiresAt time.Time `gorm:"not null;index"`
+					Revoked   *bool     `gorm:"type:tinyint(1);not null;default:0"`
+				}
+				return tx.AutoMigrate(&Session{})
+			},
+		},
+		{
+			ID: "create-api-key",
+			Migrate: func(tx *gorm.DB) error {
+				type APIKey struct {
+					model.Model
+					ID          string            `gorm:"type:varchar(64);not null;primaryKey"`
+					KeyHash     string            `gorm:"type:varchar(66);not null;uniqueIndex"`
+					GrantHash   string            `gorm:"type:varchar(66);not null;uniqueIndex"`
+					User        string            `gorm:"type:varchar(255);not null;index"`
+					Name        string            `gorm:"type:varchar(255);not null;default:''"`
+					SpendingCap string            `gorm:"type:varchar(255);not null;default:''"`
+					Spent       string            `gorm:"type:varchar(255);not null;default:'0'"`
+					Models      model.StringSlice `gorm:"type:json;not null;default:('[]')"`
+					ExpiresAt   time.Time         `gorm:"not null"`
+					Revoked     *bool             `gorm:"type:tinyint(1);not null;default:0"`
+				}
+				return tx.AutoMigrate(&APIKey{})
+			},
+		},
+		{
+			ID: "add-api-key-id-to-request",
+			Migrate: func(tx *gorm.DB) error {
+				type Request struct {
+					APIKeyID string `gorm:"type:varchar(64);not null;default:'';index"`
+				}
+				return tx.AutoMigrate(&Request{})
+			},
+		},
+		{
+			ID: "add-session-spend",
+			Migrate: func(tx *gorm.DB) error {
+				type Session struct {
+					Spent string `gorm:"type:varchar(255);not null;default:'0'"`
+				}
+				type Request struct {
+					SessionNonce string `gorm:"type:varchar(255);not null;default:''"`
+				}
+				if err := tx.AutoMigrate(&Session{}); err != nil {
+					return err
+				}
+				return tx.AutoMigrate(&Request{})
+			},
+		},
+		{
+			ID: "add-request-price",
+			Migrate: func(tx *gorm.DB) error {
+				type Request struct {
+					InputPrice  int64 `gorm:"type:bigint;not null;default:0"`
+					OutputPrice int64 `gorm:"type:bigint;not null;default:0"`
+				}
+				if err := tx.AutoMigrate(&Request{}); err != nil {
+					return err
+				}
+				// Existing requests are priced at the current prices of their service, requests
+				// without a known service at the primary one, as they were before
+				if err := tx.Exec("UPDATE `request` SET `input_price` = ?, `output_price` = ?",
+					services[0].InputPrice, services[0].OutputPrice).Error; err != nil {
+					return err
+				}
+				for _, svc := range services[1:] {
+					if err := tx.Exec("UPDATE `request` SET `input_price` = ?, `output_price` = ? WHERE `service_name` = ?",
+						svc.InputPrice, svc.OutputPrice, svc.ModelType).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "use-decimal-amounts",
+			Migrate: func(tx *gorm.DB) error {
+				columns := map[string][]string{
+					"request": {"input_fee", "output_fee", "fee", "input_price", "output_price"},
+					"user":    {"lock_balance"},
+					"api_key": {"spent"},
+					"session": {"spent"},
+				}
+				for _, table := range []string{"request", "user", "api_key", "session"} {
+					for _, column := range columns[table] {
+						// Requests are created before their output fee is known, it was left empty
+						if err := tx.Exec("UPDATE `" + table + "` SET `" + column + "` = '0' WHERE `" + column + "` = ''").Error; err != nil {
+							return err
+						}
+						if err := tx.Exec("ALTER TABLE `" + table + "` MODIFY `" + column + "` DECIMAL(65,0) NOT NULL DEFAULT 0").Error; err != nil {
+							return err
+						}
+					}
+				}
+
+				// An empty spending cap meant no cap, it is NULL now
+				if err := tx.Exec("ALTER TABLE `api_key` MODIFY `spending_cap` VARCHAR(255) NULL").Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("UPDATE `api_key` SET `spending_cap` = NULL WHERE `spending_cap` = ''").Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE `api_key` MODIFY `spending_cap` DECIMAL(65,0) NULL").Error; err != nil {
+					return err
+				}
+
+				type Request struct {
+					PriceUnit int64 `gorm:"type:bigint;not null;default:1"`
+				}
+				if err := tx.AutoMigrate(&Request{}); err != nil {
+					return err
+				}
+				// The prices backfilled by add-request-price are quoted per price unit of the service,
+				// the existing requests get the same unit
+				if err := tx.Exec("UPDATE `request` SET `price_unit` = ?", services[0].PriceUnit).Error; err != nil {
+					return err
+				}
+				for _, svc := range services[1:] {
+					if err := tx.Exec("UPDATE `request` SET `price_unit` = ? WHERE `service_name` = ?",
+						svc.PriceUnit, svc.ModelType).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "create-balance-hold",
+			Migrate: func(tx *gorm.DB) error {
+				type BalanceHold struct {
+					model.Model
+					RequestHash string      `gorm:"type:varchar(255);not null;primaryKey"`
+					User        string      `gorm:"type:varchar(255);not null;index"`
+					Amount      util.Amount `gorm:"type:decimal(65,0);not null;default:0"`
+					ExpiresAt   time.Time   `gorm:"not null;index"`
+				}
+				return tx.AutoMigrate(&BalanceHold{})
+			},
+		},
+		{
+			ID: "create-usage-record",
+			Migrate: func(tx *gorm.DB) error {
+				type UsageRecord struct {
+					model.Model
+					RequestHash  string      `gorm:"type:varchar(255);not null;primaryKey"`
+					UserAddress  string      `gorm:"type:varchar(255);not null;index:user_settledAt"`
+					Nonce        string      `gorm:"type:varchar(255);not null"`
+					ServiceName  string      `gorm:"type:varchar(255);not null"`
+					InputCount   int64       `gorm:"type:bigint;not null;default:0"`
+					OutputCount  int64       `gorm:"type:bigint;not null;default:0"`
+					InputPrice   util.Amount `gorm:"type:decimal(65,0);not null;default:0"`
+					OutputPrice  util.Amount `gorm:"type:decimal(65,0);not null;default:0"`
+					PriceUnit    int64       `gorm:"type:bigint;not null;default:1"`
+					InputFee     util.Amount `gorm:"type:decimal(65,0);not null;default:0"`
+					OutputFee    util.Amount `gorm:"type:decimal(65,0);not null;default:0"`
+					Fee          util.Amount `gorm:"type:decimal(65,0);not null;default:0"`
+					UsageSource  string      `gorm:"type:varchar(32);not null;default:''"`
+					Truncated    bool        `gorm:"type:tinyint(1);not null;default:0"`
+					APIKeyID     string      `gorm:"type:varchar(64);not null;default:''"`
+					SessionNonce string      `gorm:"type:varchar(255);not null;default:''"`
+					RequestedAt  *time.Time
+					SettledAt    time.Time `gorm:"not null;index:user_settledAt;index"`
+					TxHash       string    `gorm:"type:varchar(66);not null;index"`
+					BatchID      string    `gorm:"type:varchar(64);not null;index"`
+				}
+				return tx.AutoMigrate(&UsageRecord{})
+			},
+		},
+		{
+			ID: "add-session-nonce-to-balance-hold",
+			Migrate: func(tx *gorm.DB) error {
+				type BalanceHold struct {
+					SessionNonce string `gorm:"type:varchar(255);not null;default:'';index"`
+				}
+				return tx.AutoMigrate(&BalanceHold{})
+			},
+		},
+		{
+			ID: "add-api-key-id-to-balance-hold",
+			Migrate: func(tx *gorm.DB) error {
+				type BalanceHold struct {
+					APIKeyID string `gorm:"type:varchar(64);not null;default:'';index"`
+				}
+				return tx.AutoMigrate(&BalanceHold{})
+			},
+		},
 	})
 
 	return errors.Wrap(m.Migrate(), "migrate database")
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"gorm.io/gorm/clause"
+
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+func (d *DB) GetUserRateLimit(userAddress string) (model.UserRateLimit, error) {
+	limit := model.UserRateLimit{}
+	ret := d.db.Where(&model.UserRateLimit{User: userAddress}).First(&limit)
+	return limit, ret.Error
+}
+
+// PutUserRateLimit creates or replaces the rate limit override of a user
+func (d *DB) PutUserRateLimit(limit model.UserRateLimit) error {
+	return d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user"}},
+		DoUpdates: clause.AssignmentColumns([]string{"requests_per_minute", "tokens_per_minute", "max_concurrent_streams", "updated_at"}),
+	}).Create(&limit).Error
+}
+
+func (d *DB) DeleteUserRateLimit(userAddress string) error {
+	return d.db.Where("user = ?", userAddress).Delete(&model.UserRateLimit{}).Error
+}
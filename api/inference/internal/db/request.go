@@ -1,10 +1,10 @@
 package db
 
 import (
-	"database/sql"
-	"math/big"
+	"strings"
 	"time"
 
+	"github.com/0glabs/0g-serving-broker/common/util"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 	"gorm.io/gorm"
 )
@@ -15,9 +15,9 @@ func (d *DB) GetRequest(requestHash string) (model.Request, error) {
 	return req, ret.Error
 }
 
-func (d *DB) ListRequest(q model.RequestListOptions) ([]model.Request, int, error) {
+func (d *DB) ListRequest(q model.RequestListOptions) ([]model.Request, util.Amount, error) {
 	list := []model.Request{}
-	var totalFee sql.NullInt64
+	var totalFee util.Amount
 
 	err := d.db.Transaction(func(tx *gorm.DB) error {
 		ret := tx.Model(model.Request{}).
@@ -42,19 +42,12 @@ func (d *DB) ListRequest(q model.RequestListOptions) ([]model.Request, int, erro
 			return err
 		}
 
-		if err := ret.Select("SUM(CAST(fee AS SIGNED))").Scan(&totalFee).Error; err != nil {
+		if err := ret.Select("COALESCE(SUM(fee), 0)").Scan(&totalFee).Error; err != nil {
 			return err
 		}
 		return nil
 	})
-
-	var totalFeeInt int
-	if totalFee.Valid {
-		totalFeeInt = int(totalFee.Int64)
-	} else {
-		totalFeeInt = 0
-	}
-	return list, totalFeeInt, err
+	return list, totalFee, err
 }
 
 func (d *DB) UpdateRequest(latestReqCreateAt *time.Time) error {
@@ -87,7 +80,7 @@ func (d *DB) DeleteSettledRequestsExcludingUsers(latestReqCreateAt *time.Time, e
 	return ret.Error
 }
 
-func (d *DB) UpdateOutputFee(requestHash, userAddress, outputFee, fee, unsettledFee string) error {
+func (d *DB) UpdateOutputFee(requestHash, userAddress string, outputFee, fee util.Amount) error {
 	return d.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.
 			Where(&model.Request{
@@ -107,7 +100,7 @@ func (d *DB) UpdateOutputFee(requestHash, userAddress, outputFee, fee, unsettled
 
 // UpdateRequestFeesAndCount updates the request's output fee, total fee, and output count
 // This is the optimized version that also updates count fields for efficient aggregation
-func (d *DB) UpdateRequestFeesAndCount(requestHash, outputFee, fee string, outputCount int64) error {
+func (d *DB) UpdateRequestFeesAndCount(requestHash string, outputFee, fee util.Amount, outputCount int64) error {
 	return d.db.
 		Where(&model.Request{
 			RequestHash: requestHash,
@@ -120,19 +113,43 @@ func (d *DB) UpdateRequestFeesAndCount(requestHash, outputFee, fee string, outpu
 }
 
 // UpdateRequestWithAccurateTokens updates the request with accurate token counts from LLM response
-// This replaces the estimated values with actual values provided by the LLM
-func (d *DB) UpdateRequestWithAccurateTokens(requestHash, inputFee, outputFee, totalFee string, inputCount, outputCount int64) error {
-	return d.db.
-		Where(&model.Request{
-			RequestHash: requestHash,
-		}).
-		Updates(&model.Request{
-			InputFee:    inputFee,
-			OutputFee:   outputFee,
-			Fee:         totalFee,
-			InputCount:  inputCount,
-			OutputCount: outputCount,
-		}).Error
+// This replaces the estimated values with actual values, usageSource records where they come from
+// and truncated marks a stream cut short by the client. The balance hold of the request is
+// converted into its fee in the same transaction, so that the balance is never counted twice, and
+// the fee is added to the spending of the session or API key of the request along with it
+func (d *DB) UpdateRequestWithAccurateTokens(requestHash string, inputFee, outputFee, totalFee util.Amount, inputCount, outputCount int64, usageSource string, truncated bool) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Where(&model.Request{
+				RequestHash: requestHash,
+			}).
+			Updates(&model.Request{
+				InputFee:    inputFee,
+				OutputFee:   outputFee,
+				Fee:         totalFee,
+				InputCount:  inputCount,
+				OutputCount: outputCount,
+				UsageSource: usageSource,
+				Truncated:   truncated,
+			}).Error; err != nil {
+			return err
+		}
+		request := model.Request{}
+		if err := tx.Where("request_hash = ?", requestHash).First(&request).Error; err != nil {
+			return err
+		}
+		if request.SessionNonce != "" {
+			if err := addSessionSpend(tx, strings.ToLower(request.UserAddress), request.SessionNonce, totalFee); err != nil {
+				return err
+			}
+		}
+		if request.APIKeyID != "" {
+			if err := addAPIKeySpend(tx, request.APIKeyID, totalFee); err != nil {
+				return err
+			}
+		}
+		return tx.Where("request_hash = ?", requestHash).Delete(&model.BalanceHold{}).Error
+	})
 }
 
 func (d *DB) CreateRequest(req model.Request) error {
@@ -151,34 +168,15 @@ func (d *DB) PruneRequest(pruneThreshold time.Duration) error {
 }
 
 // CalculateUnsettledFee calculates unsettled fee using SUM aggregation for optimal performance
-// Uses database aggregation instead of application-level calculation
-func (d *DB) CalculateUnsettledFee(userAddress string, inputPrice, outputPrice int64) (*big.Int, error) {
-	type AggregateResult struct {
-		TotalInputCount  int64
-		TotalOutputCount int64
-	}
-	
-	var result AggregateResult
+// Uses database aggregation instead of application-level calculation, the fee of each request was
+// billed at the unit prices snapshotted on it
+func (d *DB) CalculateUnsettledFee(userAddress string) (util.Amount, error) {
+	var totalFee util.Amount
 	err := d.db.Model(&model.Request{}).
-		Select("COALESCE(SUM(input_count), 0) as total_input_count, COALESCE(SUM(output_count), 0) as total_output_count").
+		Select("COALESCE(SUM(fee), 0)").
 		Where("user_address = ? AND processed = ?", userAddress, false).
-		Scan(&result).Error
-	
-	if err != nil {
-		return nil, err
-	}
-	
-	// Calculate total fee: (inputCount * inputPrice) + (outputCount * outputPrice)
-	inputFee := big.NewInt(result.TotalInputCount)
-	inputFee.Mul(inputFee, big.NewInt(inputPrice))
-	
-	outputFee := big.NewInt(result.TotalOutputCount)
-	outputFee.Mul(outputFee, big.NewInt(outputPrice))
-	
-	totalFee := big.NewInt(0)
-	totalFee.Add(inputFee, outputFee)
-	
-	return totalFee, nil
+		Scan(&totalFee).Error
+	return totalFee, err
 }
 
 // UpdateRequestsSkipUntil updates the skip_until field for multiple requests
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// RegisterSession records the nonce of a session the first time it is seen and returns the stored
+// session, which belongs to another token when the nonce was already used
+func (d *DB) RegisterSession(session model.Session) (model.Session, error) {
+	if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&session).Error; err != nil {
+		return session, err
+	}
+	stored := model.Session{}
+	ret := d.db.Where(&model.Session{Address: session.Address, Nonce: session.Nonce}).First(&stored)
+	return stored, ret.Error
+}
+
+// CreateSession records the nonce of a session, it fails when the nonce was already used
+func (d *DB) CreateSession(session model.Session) error {
+	return d.db.Create(&session).Error
+}
+
+func (d *DB) GetSession(address, nonce string) (model.Session, error) {
+	session := model.Session{}
+	ret := d.db.Where(&model.Session{Address: address, Nonce: nonce}).First(&session)
+	return session, ret.Error
+}
+
+// addSessionSpend adds a billed fee to the spending of a session, in one statement so that
+// concurrent requests do not lose updates
+func addSessionSpend(tx *gorm.DB, address, nonce string, fee util.Amount) error {
+	return tx.Model(&model.Session{}).
+		Where("address = ? AND nonce = ?", address, nonce).
+		Update("spent", gorm.Expr("spent + CAST(? AS DECIMAL(65,0))", fee)).Error
+}
+
+// RevokeSession marks the session of a nonce as revoked, recording the nonce if it was never used
+func (d *DB) RevokeSession(session model.Session) error {
+	revoked := true
+	session.Revoked = &revoked
+	return d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}, {Name: "nonce"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked", "updated_at"}),
+	}).Create(&session).Error
+}
+
+// PruneSessions deletes the sessions expired before the cutoff, their tokens are rejected anyway
+func (d *DB) PruneSessions(cutoff time.Time) error {
+	return d.db.Where("expires_at <= ?", cutoff).Delete(&model.Session{}).Error
+}
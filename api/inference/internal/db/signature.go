@@ -0,0 +1,35 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// PutSignature stores a signature, replacing the previous one of the same chat
+func (d *DB) PutSignature(sig model.ChatSignature) error {
+	return d.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&sig).Error
+}
+
+func (d *DB) GetSignatureByChatID(chatID string) (model.ChatSignature, error) {
+	sig := model.ChatSignature{}
+	ret := d.db.Where(&model.ChatSignature{ChatID: chatID}).First(&sig)
+	return sig, ret.Error
+}
+
+func (d *DB) GetSignatureByRequestHash(requestHash string) (model.ChatSignature, error) {
+	sig := model.ChatSignature{}
+	ret := d.db.Where(&model.ChatSignature{RequestHash: requestHash}).Order("created_at DESC").First(&sig)
+	return sig, ret.Error
+}
+
+// PruneSignatures deletes signatures older than the retention period, a zero retention keeps them forever
+func (d *DB) PruneSignatures(retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	cutoffTime := time.Now().Add(-retention)
+	return d.db.Where("created_at <= ?", cutoffTime).Delete(&model.ChatSignature{}).Error
+}
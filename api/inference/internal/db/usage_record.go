@@ -0,0 +1,100 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+const (
+	defaultUsageRecordLimit = 100
+	maxUsageRecordLimit     = 1000
+)
+
+// SettleRequests moves settled requests to the usage ledger, the records are created and the
+// requests deleted in one transaction. A record already in the ledger is kept as is
+func (d *DB) SettleRequests(records []model.UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	requestHashes := make([]string, len(records))
+	for i, record := range records {
+		requestHashes[i] = record.RequestHash
+	}
+
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error; err != nil {
+			return err
+		}
+		return tx.Where("request_hash IN ?", requestHashes).Delete(&model.Request{}).Error
+	})
+}
+
+// ListUsageRecord lists the usage records matching opt, most recently settled first. The totals
+// cover every matching record, the items only the page selected by Limit and Offset
+func (d *DB) ListUsageRecord(opt model.UsageRecordListOptions) (model.UsageRecordList, error) {
+	list := model.UsageRecordList{}
+	query := d.db.Model(&model.UsageRecord{})
+	if opt.User != "" {
+		query = query.Where("user_address = ?", opt.User)
+	}
+	if opt.TxHash != "" {
+		query = query.Where("tx_hash = ?", opt.TxHash)
+	}
+	if opt.BatchID != "" {
+		query = query.Where("batch_id = ?", opt.BatchID)
+	}
+	if opt.From != nil {
+		query = query.Where("settled_at >= ?", *opt.From)
+	}
+	if opt.To != nil {
+		query = query.Where("settled_at < ?", *opt.To)
+	}
+
+	var totals struct {
+		Total       int64
+		InputCount  int64
+		OutputCount int64
+		Fee         util.Amount
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(input_count), 0) AS input_count, COALESCE(SUM(output_count), 0) AS output_count, COALESCE(SUM(fee), 0) AS fee").
+		Scan(&totals).Error; err != nil {
+		return list, err
+	}
+
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = defaultUsageRecordLimit
+	}
+	if limit > maxUsageRecordLimit {
+		limit = maxUsageRecordLimit
+	}
+	if err := query.Session(&gorm.Session{}).
+		Order("settled_at DESC, request_hash").
+		Limit(limit).
+		Offset(opt.Offset).
+		Find(&list.Items).Error; err != nil {
+		return list, err
+	}
+
+	list.Metadata = model.ListMeta{Total: uint64(totals.Total)}
+	list.InputCount = totals.InputCount
+	list.OutputCount = totals.OutputCount
+	list.Fee = totals.Fee
+	return list, nil
+}
+
+// PruneUsageRecords deletes the usage records settled longer than the retention period ago, a zero
+// retention keeps them forever
+func (d *DB) PruneUsageRecords(retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	cutoffTime := time.Now().Add(-retention)
+	return d.db.Where("settled_at <= ?", cutoffTime).Delete(&model.UsageRecord{}).Error
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/0glabs/0g-serving-broker/common/util"
 	constant "github.com/0glabs/0g-serving-broker/inference/const"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 )
@@ -103,7 +104,7 @@ func (d *DB) BatchUpdateUserAccount(news []model.User) error {
 	return d.DeleteUserAccounts(toRemove)
 }
 
-func (d *DB) ListUsersWithUnsettledFees(opt *model.UserListOptions, inputPrice, outputPrice int64) ([]model.User, error) {
+func (d *DB) ListUsersWithUnsettledFees(opt *model.UserListOptions) ([]model.User, error) {
 	if opt == nil {
 		opt = &model.UserListOptions{}
 	}
@@ -114,12 +115,12 @@ func (d *DB) ListUsersWithUnsettledFees(opt *model.UserListOptions, inputPrice,
 			u.user,
 			u.lock_balance,
 			u.last_balance_check_time,
-			COALESCE(SUM(r.input_count * ? + r.output_count * ?), 0) as calculated_unsettled_fee
+			COALESCE(SUM(r.fee), 0) as calculated_unsettled_fee
 		FROM user u
 		LEFT JOIN request r ON u.user = r.user_address AND r.processed = false
 		WHERE (u.skip_until IS NULL OR u.skip_until <= ?)
 	`
-	args := []interface{}{inputPrice, outputPrice, time.Now()}
+	args := []interface{}{time.Now()}
 
 	// Group by user fields
 	query += " GROUP BY u.user, u.lock_balance, u.last_balance_check_time"
@@ -128,19 +129,19 @@ func (d *DB) ListUsersWithUnsettledFees(opt *model.UserListOptions, inputPrice,
 	havingClauses := []string{}
 
 	if opt.MinUnsettledFee != nil {
-		havingClauses = append(havingClauses, "calculated_unsettled_fee > ?")
+		havingClauses = append(havingClauses, "calculated_unsettled_fee > CAST(? AS DECIMAL(65,0))")
 		args = append(args, *opt.MinUnsettledFee)
 	}
 
 	// Preserve original OR logic: (balance_condition OR time_condition)
 	if opt.LowBalanceRisk != nil && opt.SettleTriggerThreshold != nil {
 		havingClauses = append(havingClauses,
-			"((CAST(u.lock_balance AS SIGNED) - calculated_unsettled_fee) < ? OR u.last_balance_check_time < ?)")
+			"((u.lock_balance - calculated_unsettled_fee) < CAST(? AS DECIMAL(65,0)) OR u.last_balance_check_time < ?)")
 		args = append(args, *opt.SettleTriggerThreshold, *opt.LowBalanceRisk)
 	} else if opt.SettleTriggerThreshold != nil {
 		// Only check balance condition if no time filter - match original logic
 		havingClauses = append(havingClauses,
-			"(CAST(u.lock_balance AS SIGNED) - calculated_unsettled_fee) < ?")
+			"(u.lock_balance - calculated_unsettled_fee) < CAST(? AS DECIMAL(65,0))")
 		args = append(args, *opt.SettleTriggerThreshold)
 	} else if opt.LowBalanceRisk != nil {
 		// Only check time condition if no threshold
@@ -158,9 +159,9 @@ func (d *DB) ListUsersWithUnsettledFees(opt *model.UserListOptions, inputPrice,
 	// Execute the query
 	type QueryResult struct {
 		User                   string
-		LockBalance            *string
+		LockBalance            *util.Amount
 		LastBalanceCheckTime   *time.Time
-		CalculatedUnsettledFee int64
+		CalculatedUnsettledFee util.Amount
 	}
 
 	var results []QueryResult
@@ -11,10 +11,12 @@ import (
 
 // listUserAccount
 //
-//	@Description	This endpoint allows you to list all users who have created accounts for your service
+//	@Description	This endpoint allows you to list all users who have created accounts for your service. The read-only role is required
 //	@ID			listUserAccount
 //	@Tags		user
 //	@Router		/user [get]
+//	@Security	AdminToken
+//	@Security	AdminSignature
 //	@Success	200	{object}	model.UserList
 func (h *Handler) ListUserAccount(ctx *gin.Context) {
 	list, err := h.ctrl.ListUserAccount(ctx, true)
@@ -31,10 +33,12 @@ func (h *Handler) ListUserAccount(ctx *gin.Context) {
 
 // getUserAccount
 //
-//	@Description	This endpoint allows you to get account by user address
+//	@Description	This endpoint allows you to get account by user address. The read-only role is required
 //	@ID			getUserAccount
 //	@Tags		user
 //	@Router		/user/{user} [get]
+//	@Security	AdminToken
+//	@Security	AdminSignature
 //	@Param		user	path	string	true	"User address"
 //	@Success	200	{object}	model.User
 func (h *Handler) GetUserAccount(ctx *gin.Context) {
@@ -50,10 +54,12 @@ func (h *Handler) GetUserAccount(ctx *gin.Context) {
 
 // syncUserAccounts
 //
-//	@Description  This endpoint allows you to synchronize information of all accounts from the contract
+//	@Description  This endpoint allows you to synchronize information of all accounts from the contract. The operator role is required
 //	@ID			syncUserAccounts
 //	@Tags		user
 //	@Router		/sync-account [post]
+//	@Security	AdminToken
+//	@Security	AdminSignature
 //	@Success	202
 func (h *Handler) SyncUserAccounts(ctx *gin.Context) {
 	if err := h.ctrl.SyncUserAccounts(ctx); err != nil {
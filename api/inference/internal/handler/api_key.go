@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/internal/ctrl"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// createAPIKey
+//
+//	@Description	This endpoint allows a user to mint an API key billed to its account. The grant is a JSON string of ctrl.APIKeyGrant, signed by the user like a session token. The key is returned once and is sent as "Authorization: Bearer <key>" to the proxy
+//	@ID			createAPIKey
+//	@Tags		apiKey
+//	@Router		/api-key [post]
+//	@Param		body	body	ctrl.CreateAPIKeyRequest	true	"body"
+//	@Success	201	{object}	ctrl.CreatedAPIKey
+func (h *Handler) CreateAPIKey(ctx *gin.Context) {
+	var req ctrl.CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		handleBrokerError(ctx, err, "bind API key grant")
+		return
+	}
+	key, err := h.ctrl.CreateAPIKey(ctx, req)
+	if err != nil {
+		handleBrokerError(ctx, err, "create API key")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, key)
+}
+
+// listAPIKey
+//
+//	@Description	This endpoint allows you to list the API keys of a user, with their spending. The read-only role is required
+//	@ID			listAPIKey
+//	@Tags		apiKey
+//	@Router		/user/{user}/api-key [get]
+//	@Security	AdminToken
+//	@Security	AdminSignature
+//	@Param		user	path	string	true	"User address"
+//	@Success	200	{object}	model.APIKeyList
+func (h *Handler) ListAPIKey(ctx *gin.Context) {
+	userAddress := ctx.Param("user")
+	if !common.IsHexAddress(userAddress) {
+		handleBrokerError(ctx, errors.New("invalid user address"), "list API key")
+		return
+	}
+	list, err := h.ctrl.ListAPIKey(userAddress)
+	if err != nil {
+		handleBrokerError(ctx, err, "list API key")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIKeyList{
+		Metadata: model.ListMeta{Total: uint64(len(list))},
+		Items:    list,
+	})
+}
+
+// listUserAPIKey
+//
+//	@Description	This endpoint allows a user to list its own API keys, with their spending. It is authenticated with the Address, Session-Token and Session-Signature headers of a session, not with an API key
+//	@ID			listUserAPIKey
+//	@Tags		apiKey
+//	@Router		/api-key [get]
+//	@Success	200	{object}	model.APIKeyList
+func (h *Handler) ListUserAPIKey(ctx *gin.Context) {
+	if err := h.ctrl.ValidateSession(ctx); err != nil {
+		handleBrokerError(ctx, errors.Wrap(err, "session validation failed"), "list API key")
+		return
+	}
+	list, err := h.ctrl.ListAPIKey(ctx.GetHeader("Address"))
+	if err != nil {
+		handleBrokerError(ctx, err, "list API key")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIKeyList{
+		Metadata: model.ListMeta{Total: uint64(len(list))},
+		Items:    list,
+	})
+}
+
+// revokeUserAPIKey
+//
+//	@Description	This endpoint allows a user to revoke one of its API keys. The revocation is a JSON string of ctrl.APIKeyRevocation, signed by the user like a session token
+//	@ID			revokeUserAPIKey
+//	@Tags		apiKey
+//	@Router		/api-key/revoke [post]
+//	@Param		body	body	ctrl.RevokeAPIKeyRequest	true	"body"
+//	@Success	204
+func (h *Handler) RevokeUserAPIKey(ctx *gin.Context) {
+	var req ctrl.RevokeAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		handleBrokerError(ctx, err, "bind API key revocation")
+		return
+	}
+	if err := h.ctrl.RevokeUserAPIKey(ctx, req); err != nil {
+		handleBrokerError(ctx, err, "revoke API key")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// revokeAPIKey
+//
+//	@Description	This endpoint allows an operator to revoke any API key. The operator role is required
+//	@ID			revokeAPIKey
+//	@Tags		apiKey
+//	@Router		/api-key/{id} [delete]
+//	@Security	AdminToken
+//	@Security	AdminSignature
+//	@Param		id	path	string	true	"API key id"
+//	@Success	204
+func (h *Handler) RevokeAPIKey(ctx *gin.Context) {
+	if err := h.ctrl.RevokeAPIKey(ctx.Param("id")); err != nil {
+		handleBrokerError(ctx, err, "revoke API key")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/inference/internal/ctrl"
+)
+
+// adminAuth rejects the callers of the management API without the required role
+func (h *Handler) adminAuth(required string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		role, err := h.ctrl.AuthenticateAdmin(ctx)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Provider: authenticate admin: " + err.Error()})
+			return
+		}
+		if !ctrl.RoleAllows(role, required) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Provider: role " + role + " is not allowed, " + required + " is required"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/config"
 	"github.com/0glabs/0g-serving-broker/inference/internal/ctrl"
 	"github.com/0glabs/0g-serving-broker/inference/internal/proxy"
 )
@@ -26,7 +27,7 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Admin-Timestamp, Admin-Signature, Address, Session-Token, Session-Signature")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
 
@@ -45,16 +46,41 @@ func (h *Handler) Register(r *gin.Engine) {
 	// service
 	group.GET("/service", corsMiddleware(), h.GetService)
 
+	readOnly := h.adminAuth(config.RoleReadOnly)
+	operator := h.adminAuth(config.RoleOperator)
+
 	// settle
-	group.POST("/settle", corsMiddleware(), h.SettleFees)
+	group.POST("/settle", corsMiddleware(), operator, h.SettleFees)
 
 	// account
-	group.GET("/user", corsMiddleware(), h.ListUserAccount)
-	group.GET("/user/:user", corsMiddleware(), h.GetUserAccount)
-	group.POST("sync-account", corsMiddleware(), h.SyncUserAccounts)
+	group.GET("/user", corsMiddleware(), readOnly, h.ListUserAccount)
+	group.GET("/user/:user", corsMiddleware(), readOnly, h.GetUserAccount)
+	group.GET("/user/:user/rate-limit", corsMiddleware(), readOnly, h.GetUserRateLimit)
+	group.PUT("/user/:user/rate-limit", corsMiddleware(), operator, h.PutUserRateLimit)
+	group.DELETE("/user/:user/rate-limit", corsMiddleware(), operator, h.DeleteUserRateLimit)
+	group.POST("sync-account", corsMiddleware(), operator, h.SyncUserAccounts)
+
+	// session
+	group.POST("/session/revoke", corsMiddleware(), h.RevokeSession)
+	group.OPTIONS("/session/revoke", corsMiddleware())
+	group.GET("/siwe/nonce", corsMiddleware(), h.GetSIWENonce)
+	group.POST("/siwe/login", corsMiddleware(), h.LoginWithSIWE)
+	group.OPTIONS("/siwe/login", corsMiddleware())
+
+	// api key
+	group.POST("/api-key", corsMiddleware(), h.CreateAPIKey)
+	group.OPTIONS("/api-key", corsMiddleware())
+	group.GET("/api-key", corsMiddleware(), h.ListUserAPIKey)
+	group.POST("/api-key/revoke", corsMiddleware(), h.RevokeUserAPIKey)
+	group.OPTIONS("/api-key/revoke", corsMiddleware())
+	group.GET("/user/:user/api-key", corsMiddleware(), readOnly, h.ListAPIKey)
+	group.DELETE("/api-key/:id", corsMiddleware(), operator, h.RevokeAPIKey)
 
 	// request
-	group.GET("/request", corsMiddleware(), h.ListRequest)
+	group.GET("/request", corsMiddleware(), readOnly, h.ListRequest)
+
+	// usage ledger
+	group.GET("/usage", corsMiddleware(), readOnly, h.ListUsageRecord)
 
 	group.GET("/quote", corsMiddleware(), h.GetQuote)
 
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// getUserRateLimit
+//
+//	@Description	This endpoint allows you to get the effective rate limit of a user and its override, if any. The read-only role is required
+//	@ID			getUserRateLimit
+//	@Tags		user
+//	@Router		/user/{user}/rate-limit [get]
+//	@Security	AdminToken
+//	@Security	AdminSignature
+//	@Param		user	path	string	true	"User address"
+//	@Success	200	{object}	ctrl.UserRateLimit
+func (h *Handler) GetUserRateLimit(ctx *gin.Context) {
+	userAddress := ctx.Param("user")
+	if !common.IsHexAddress(userAddress) {
+		handleBrokerError(ctx, errors.New("invalid user address"), "get user rate limit")
+		return
+	}
+	limit, err := h.ctrl.GetUserRateLimit(userAddress)
+	if err != nil {
+		handleBrokerError(ctx, err, "get user rate limit")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, limit)
+}
+
+// putUserRateLimit
+//
+//	@Description	This endpoint allows you to override the default rate limit of a user. Omitted limits keep the default, and 0 removes a limit. The operator role is required
+//	@ID			putUserRateLimit
+//	@Tags		user
+//	@Router		/user/{user}/rate-limit [put]
+//	@Security	AdminToken
+//	@Security	AdminSignature
+//	@Param		user	path	string				true	"User address"
+//	@Param		body	body	model.UserRateLimit	true	"body"
+//	@Success	204
+func (h *Handler) PutUserRateLimit(ctx *gin.Context) {
+	userAddress := ctx.Param("user")
+	if !common.IsHexAddress(userAddress) {
+		handleBrokerError(ctx, errors.New("invalid user address"), "put user rate limit")
+		return
+	}
+	var limit model.UserRateLimit
+	if err := ctx.ShouldBindJSON(&limit); err != nil {
+		handleBrokerError(ctx, err, "bind user rate limit")
+		return
+	}
+	for _, v := range []*int64{limit.RequestsPerMinute, limit.TokensPerMinute, limit.MaxConcurrentStreams} {
+		if v != nil && *v < 0 {
+			handleBrokerError(ctx, errors.New("limits must not be negative"), "put user rate limit")
+			return
+		}
+	}
+	limit.User = userAddress
+	if err := h.ctrl.SetUserRateLimit(limit); err != nil {
+		handleBrokerError(ctx, err, "put user rate limit")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// deleteUserRateLimit
+//
+//	@Description	This endpoint allows you to remove the rate limit override of a user, the default rate limit applies again. The operator role is required
+//	@ID			deleteUserRateLimit
+//	@Tags		user
+//	@Router		/user/{user}/rate-limit [delete]
+//	@Security	AdminToken
+//	@Security	AdminSignature
+//	@Param		user	path	string	true	"User address"
+//	@Success	204
+func (h *Handler) DeleteUserRateLimit(ctx *gin.Context) {
+	userAddress := ctx.Param("user")
+	if !common.IsHexAddress(userAddress) {
+		handleBrokerError(ctx, errors.New("invalid user address"), "delete user rate limit")
+		return
+	}
+	if err := h.ctrl.DeleteUserRateLimit(userAddress); err != nil {
+		handleBrokerError(ctx, err, "delete user rate limit")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -10,10 +10,12 @@ import (
 
 // listRequest
 //
-//	@Description	This endpoint allows you to list requests
+//	@Description	This endpoint allows you to list requests. The read-only role is required
 //	@ID			listRequest
 //	@Tags		request
 //	@Router		/request [get]
+//	@Security	AdminToken
+//	@Security	AdminSignature
 //	@Param		processed	query	bool	false	"Processed"
 //	@Success	200	{object}	model.RequestList
 func (h *Handler) ListRequest(ctx *gin.Context) {
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/inference/internal/ctrl"
+)
+
+// revokeSession
+//
+//	@Description	This endpoint allows a user to revoke one of its session tokens. The revocation is a JSON string of ctrl.SessionRevocation, signed by the user like a session token
+//	@ID			revokeSession
+//	@Tags		session
+//	@Router		/session/revoke [post]
+//	@Param		body	body	ctrl.RevokeSessionRequest	true	"body"
+//	@Success	204
+func (h *Handler) RevokeSession(ctx *gin.Context) {
+	var req ctrl.RevokeSessionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		handleBrokerError(ctx, err, "bind session revocation")
+		return
+	}
+	if err := h.ctrl.RevokeSession(ctx, req); err != nil {
+		handleBrokerError(ctx, err, "revoke session")
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -8,10 +8,12 @@ import (
 
 // settleFees
 //
-//	@Description  This endpoint allows you to settle fees for requests from users
+//	@Description  This endpoint allows you to settle fees for requests from users. The operator role is required
 //	@ID			settleFees
 //	@Tags		settle
 //	@Router		/settle [post]
+//	@Security	AdminToken
+//	@Security	AdminSignature
 //	@Success	202
 func (h *Handler) SettleFees(ctx *gin.Context) {
 	if err := h.ctrl.SettleFeesWithTEE(ctx); err != nil {
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/inference/internal/ctrl"
+)
+
+// getSIWENonce
+//
+//	@Description	This endpoint issues a nonce for a Sign-In-With-Ethereum login, valid for 5 minutes
+//	@ID			getSIWENonce
+//	@Tags		session
+//	@Router		/siwe/nonce [get]
+//	@Success	200	{object}	map[string]string
+func (h *Handler) GetSIWENonce(ctx *gin.Context) {
+	nonce, err := h.ctrl.SIWENonce()
+	if err != nil {
+		handleBrokerError(ctx, err, "issue SIWE nonce")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"nonce": nonce})
+}
+
+// loginWithSIWE
+//
+//	@Description	This endpoint exchanges a signed EIP-4361 message for a short-lived session, sent as the Address, Session-Token and Session-Signature headers. The message must use a nonce from /siwe/nonce, the chain ID of the provider, an expiration time and the resource urn:0g:provider:<provider address>
+//	@ID			loginWithSIWE
+//	@Tags		session
+//	@Router		/siwe/login [post]
+//	@Param		body	body	ctrl.SIWELoginRequest	true	"body"
+//	@Success	200	{object}	ctrl.SIWELoginResponse
+func (h *Handler) LoginWithSIWE(ctx *gin.Context) {
+	var req ctrl.SIWELoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		handleBrokerError(ctx, err, "bind SIWE login")
+		return
+	}
+	resp, err := h.ctrl.LoginWithSIWE(ctx, req)
+	if err != nil {
+		handleBrokerError(ctx, err, "login with SIWE")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0glabs/0g-serving-broker/inference/model"
+)
+
+// listUsageRecord
+//
+//	@Description	This endpoint allows you to list the usage records of settled requests, with the totals of the records matching the filters. The read-only role is required
+//	@ID			listUsageRecord
+//	@Tags		usage
+//	@Router		/usage [get]
+//	@Security	AdminToken
+//	@Security	AdminSignature
+//	@Param		user	query	string	false	"User address"
+//	@Param		txHash	query	string	false	"Settlement transaction hash"
+//	@Param		batchId	query	string	false	"Settlement batch ID"
+//	@Param		from	query	string	false	"Settled at or after, RFC 3339"
+//	@Param		to		query	string	false	"Settled before, RFC 3339"
+//	@Param		limit	query	int		false	"Page size, 100 by default and at most 1000"
+//	@Param		offset	query	int		false	"Page offset"
+//	@Success	200	{object}	model.UsageRecordList
+func (h *Handler) ListUsageRecord(ctx *gin.Context) {
+	var q model.UsageRecordListOptions
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		handleBrokerError(ctx, err, "list usage record")
+		return
+	}
+	list, err := h.ctrl.ListUsageRecord(q)
+	if err != nil {
+		handleBrokerError(ctx, err, "list usage record")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
@@ -1,8 +1,11 @@
 package proxy
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -13,7 +16,10 @@ import (
 
 	"github.com/0glabs/0g-serving-broker/common/errors"
 	"github.com/0glabs/0g-serving-broker/common/log"
+	"github.com/0glabs/0g-serving-broker/common/util"
+	"github.com/0glabs/0g-serving-broker/inference/config"
 	constant "github.com/0glabs/0g-serving-broker/inference/const"
+	"github.com/0glabs/0g-serving-broker/inference/internal/balancer"
 	"github.com/0glabs/0g-serving-broker/inference/internal/ctrl"
 	"github.com/0glabs/0g-serving-broker/inference/model"
 	"github.com/0glabs/0g-serving-broker/inference/monitor"
@@ -25,9 +31,12 @@ type Proxy struct {
 
 	allowOrigins      []string
 	serviceRoutesLock sync.RWMutex
-	serviceTarget     string
-	serviceType       string
-	serviceGroup      *gin.RouterGroup
+	// serviceTargets maps the served model to its service, primaryModel is used when a request names none
+	serviceTargets map[string]config.Service
+	// balancers spread the requests of each served model over its replicas
+	balancers    map[string]*balancer.Balancer
+	primaryModel string
+	serviceGroup *gin.RouterGroup
 }
 
 func New(ctrl *ctrl.Ctrl, engine *gin.Engine, allowOrigins []string, enableMonitor bool, logger log.Logger) *Proxy {
@@ -37,10 +46,12 @@ func New(ctrl *ctrl.Ctrl, engine *gin.Engine, allowOrigins []string, enableMonit
 	}
 
 	p := &Proxy{
-		allowOrigins: allowOrigins,
-		ctrl:         ctrl,
-		logger:       logger,
-		serviceGroup: engine.Group(constant.ServicePrefix),
+		allowOrigins:   allowOrigins,
+		ctrl:           ctrl,
+		logger:         logger,
+		serviceTargets: make(map[string]config.Service),
+		balancers:      make(map[string]*balancer.Balancer),
+		serviceGroup:   engine.Group(constant.ServicePrefix),
 	}
 
 	p.serviceGroup.Use(cors.New(cors.Config{
@@ -57,62 +68,130 @@ func New(ctrl *ctrl.Ctrl, engine *gin.Engine, allowOrigins []string, enableMonit
 }
 
 func (p *Proxy) Start() error {
-	switch p.ctrl.Service.Type {
-	case "zgStorage", "chatbot":
-		p.AddHTTPRoute(p.ctrl.Service.TargetURL, p.ctrl.Service.Type)
-	default:
-		return errors.New("invalid service type")
+	for _, svc := range p.ctrl.Services {
+		switch svc.Type {
+		case "zgStorage", "chatbot":
+			if err := p.AddHTTPRoute(svc); err != nil {
+				return err
+			}
+		default:
+			return errors.Wrapf(errors.New("invalid service type"), "%s", svc.ModelType)
+		}
 	}
 	return nil
 }
 
-func (p *Proxy) AddHTTPRoute(targetURL, svcType string) {
+func (p *Proxy) AddHTTPRoute(svc config.Service) error {
 	//TODO: Add a URL validation
-	exists := p.serviceTarget == targetURL
+	b, err := balancer.New(svc, p.logger)
+	if err != nil {
+		return err
+	}
+	// The probes run for the lifetime of the broker
+	go b.Run(context.Background())
 
 	p.serviceRoutesLock.Lock()
-	p.serviceTarget = targetURL
-	p.serviceType = svcType
+	exists := len(p.serviceTargets) > 0
+	if !exists {
+		p.primaryModel = svc.ModelType
+	}
+	p.serviceTargets[svc.ModelType] = svc
+	p.balancers[svc.ModelType] = b
 	p.serviceRoutesLock.Unlock()
 
 	if exists {
-		return
+		return nil
 	}
 
 	h := func(ctx *gin.Context) {
 		p.proxyHTTPRequest(ctx)
 	}
 	p.serviceGroup.Any("*any", h)
+	return nil
 }
 
-func (p *Proxy) proxyHTTPRequest(ctx *gin.Context) {
+// pickReplica selects the replica serving the request, the returned release must be called once
+// the response, including a whole stream, has been forwarded
+func (p *Proxy) pickReplica(svc config.Service, targetRoute string) (string, func(), error) {
 	p.serviceRoutesLock.RLock()
-	targetURL := p.serviceTarget
-	svcType := p.serviceType
+	b, ok := p.balancers[svc.ModelType]
 	p.serviceRoutesLock.RUnlock()
+	if !ok {
+		return "", nil, errors.Wrapf(errors.New("no balancer"), "%s", svc.ModelType)
+	}
 
-	targetRoute := strings.TrimPrefix(ctx.Request.RequestURI, constant.ServicePrefix)
+	replica, err := b.Pick()
+	if err != nil {
+		return "", nil, err
+	}
+	targetURL := replica.URL
 	if targetRoute != "/" {
 		targetURL += targetRoute
 	}
+	return targetURL, replica.Release, nil
+}
+
+// getServiceTarget returns the service for the model named in a request body.
+// An empty model selects the primary service, a model not served is rejected
+func (p *Proxy) getServiceTarget(reqBody []byte) (config.Service, error) {
+	var body struct {
+		Model string `json:"model"`
+	}
+	// Bodies of free routes may not be JSON, they go to the primary service
+	_ = json.Unmarshal(reqBody, &body)
+
+	p.serviceRoutesLock.RLock()
+	defer p.serviceRoutesLock.RUnlock()
+
+	if body.Model == "" {
+		return p.serviceTargets[p.primaryModel], nil
+	}
+	svc, ok := p.serviceTargets[body.Model]
+	if !ok {
+		return config.Service{}, errors.Wrapf(errors.New("model not served by this provider"), "%s", body.Model)
+	}
+	return svc, nil
+}
+
+func (p *Proxy) proxyHTTPRequest(ctx *gin.Context) {
 	reqBody, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		p.handleBrokerError(ctx, err, "read request body")
 		return
 	}
 
+	svc, err := p.getServiceTarget(reqBody)
+	if err != nil {
+		p.handleBrokerError(ctx, err, "match service")
+		return
+	}
+
+	targetRoute := strings.TrimPrefix(ctx.Request.RequestURI, constant.ServicePrefix)
+	route := strings.TrimPrefix(ctx.Request.URL.Path, constant.ServicePrefix)
+
 	// handle endpoints not need to be charged
-	if _, ok := constant.TargetRoute[targetRoute]; !ok {
-		if p.handleSignatureRoute(ctx, targetRoute) {
+	if _, ok := constant.TargetRoute[route]; !ok {
+		if !strings.HasPrefix(strings.ToLower(route), constant.SignatureRoutePrefix) {
+			p.handleBrokerError(ctx, errors.Wrapf(errors.New("unsupported route"), "%s", route), "match route")
+			return
+		}
+		if p.handleSignatureRoute(ctx) {
+			return
+		}
+
+		targetURL, releaseReplica, err := p.pickReplica(svc, targetRoute)
+		if err != nil {
+			p.handleBrokerError(ctx, err, "pick replica")
 			return
 		}
+		defer releaseReplica()
 
-		httpReq, err := p.ctrl.PrepareHTTPRequest(ctx, targetURL, reqBody)
+		httpReq, err := p.ctrl.PrepareHTTPRequest(ctx, svc, targetURL, reqBody)
 		if err != nil {
 			p.handleBrokerError(ctx, err, "prepare HTTP request")
 			return
 		}
-		p.ctrl.ProcessHTTPRequest(ctx, svcType, httpReq, model.Request{}, 0, false)
+		p.ctrl.ProcessHTTPRequest(ctx, svc, route, httpReq, model.Request{}, false)
 		return
 	}
 	req, err := p.ctrl.GetFromHTTPRequest(ctx)
@@ -121,12 +200,12 @@ func (p *Proxy) proxyHTTPRequest(ctx *gin.Context) {
 		return
 	}
 
-	var expectedInputFee string
-	switch svcType {
+	var expectedInputFee util.Amount
+	switch svc.Type {
 	case "zgStorage":
-		expectedInputFee = "0"
+		expectedInputFee = util.NewAmount(0)
 	case "chatbot":
-		expectedInputFee, _, err = p.ctrl.GetChatbotInputFeeAndCount(reqBody)
+		expectedInputFee, _, err = p.ctrl.GetInputFeeAndCount(svc, route, reqBody)
 		if err != nil {
 			p.handleBrokerError(ctx, err, "get input fee and count")
 			return
@@ -138,46 +217,95 @@ func (p *Proxy) proxyHTTPRequest(ctx *gin.Context) {
 
 	// Use estimated values for validation only
 	// Actual values will be set when LLM response is received
-	req.InputFee = "0"  // Will be set with actual value from LLM response
-	req.Fee = "0"       // Will be set with actual value from LLM response
-	req.InputCount = 0  // Will be set with actual token count from LLM
-	req.OutputCount = 0 // Will be updated when response is processed
+	req.InputFee = util.NewAmount(0)  // Will be set with actual value from LLM response
+	req.OutputFee = util.NewAmount(0) // Will be set with actual value from LLM response
+	req.Fee = util.NewAmount(0)       // Will be set with actual value from LLM response
+	req.InputCount = 0                // Will be set with actual token count from LLM
+	req.OutputCount = 0               // Will be updated when response is processed
 	req.Nonce = uuid.New().String()
 	req.RequestHash = req.Nonce
+	req.ServiceName = svc.ModelType
+	// Prices are snapshotted so that a price change does not apply to requests already served
+	req.InputPrice = svc.InputPrice
+	req.OutputPrice = svc.OutputPrice
+	req.PriceUnit = svc.PriceUnit
 
-	if err := p.ctrl.ValidateRequestWithEstimatedFee(ctx, req, expectedInputFee); err != nil {
+	if err := p.ctrl.ValidateRequestWithEstimatedFee(ctx, svc, req, expectedInputFee); err != nil {
 		p.handleBrokerError(ctx, err, "validate request")
 		return
 	}
+	releaseRate, err := p.ctrl.AdmitRequest(req.UserAddress, reqBody)
+	if err != nil {
+		p.handleBrokerError(ctx, err, "admit request")
+		return
+	}
+	defer releaseRate()
+	admitted, err := p.ctrl.AcquireAdmission(ctx, svc, req.UserAddress)
+	if err != nil {
+		p.handleBrokerError(ctx, err, "acquire admission")
+		return
+	}
+	defer admitted()
+	// The replica is picked before the balance is held and the request is recorded, so that a
+	// request no healthy replica can serve holds no balance and leaves no request unbilled
+	targetURL, releaseReplica, err := p.pickReplica(svc, targetRoute)
+	if err != nil {
+		p.handleBrokerError(ctx, err, "pick replica")
+		return
+	}
+	defer releaseReplica()
+	// The output is clamped before the balance is held, so that the hold covers the clamped output
+	reqBody, maxTokens, err := p.ctrl.ClampMaxTokens(ctx, svc, route, req, expectedInputFee, reqBody)
+	if err != nil {
+		p.handleBrokerError(ctx, err, "clamp max tokens")
+		return
+	}
+	if maxTokens > 0 {
+		ctx.Header(constant.MaxTokensCapHeader, strconv.FormatInt(maxTokens, 10))
+	}
+	// The balance is held once the request is admitted, so that the requests rate limited or
+	// waiting in the queue do not hold balance the other requests of the user need
+	if err := p.ctrl.ReserveBalance(ctx, svc, route, req, reqBody, expectedInputFee); err != nil {
+		p.handleBrokerError(ctx, err, "reserve balance")
+		return
+	}
+	// A no-op once the fee is billed, otherwise the balance held for the request is returned
+	defer p.ctrl.ReleaseBalanceHold(req.RequestHash)
 	if err := p.ctrl.CreateRequest(req); err != nil {
 		p.handleBrokerError(ctx, err, "create request")
 		return
 	}
 
-	httpReq, err := p.ctrl.PrepareHTTPRequest(ctx, targetURL, reqBody)
+	httpReq, err := p.ctrl.PrepareHTTPRequest(ctx, svc, targetURL, reqBody)
 	if err != nil {
 		p.handleBrokerError(ctx, err, "prepare HTTP request")
 		return
 	}
 
-	if err := p.ctrl.ProcessHTTPRequest(ctx, svcType, httpReq, req, p.ctrl.Service.OutputPrice, true); err != nil {
+	if err := p.ctrl.ProcessHTTPRequest(ctx, svc, route, httpReq, req, true); err != nil {
 		p.logger.Errorf("process http request failed: %v", err)
 	}
 }
 
-func (p *Proxy) handleSignatureRoute(ctx *gin.Context, targetRoute string) bool {
-	if !strings.HasPrefix(strings.ToLower(targetRoute), "/signature/") {
-		return false
-	}
-
+func (p *Proxy) handleSignatureRoute(ctx *gin.Context) bool {
 	vllmProxy := ctx.GetHeader("VLLM-Proxy")
-	relativePath := strings.ToLower(ctx.Param("any"))
-	chatID := strings.TrimPrefix(relativePath, "/signature/")
+	relativePath := ctx.Param("any")
 
 	if strings.ToLower(vllmProxy) != "true" {
-		sig, err := p.ctrl.GetChatSignature(chatID)
+		var sig *ctrl.ChatSignature
+		var err error
+		// Only the prefix is matched case-insensitively, the id keeps its case
+		lower := strings.ToLower(relativePath)
+		switch {
+		case strings.HasPrefix(lower, constant.SignatureByRequestRoutePrefix):
+			sig, err = p.ctrl.GetChatSignatureByRequestHash(relativePath[len(constant.SignatureByRequestRoutePrefix):])
+		case strings.HasPrefix(lower, constant.SignatureRoutePrefix):
+			sig, err = p.ctrl.GetChatSignature(relativePath[len(constant.SignatureRoutePrefix):])
+		default:
+			err = errors.Wrapf(errors.New("unsupported route"), "%s", relativePath)
+		}
 		if err != nil {
-			p.handleBrokerError(ctx, err, "prepare HTTP request")
+			p.handleBrokerError(ctx, err, "get chat signature")
 			return true
 		}
 
@@ -189,6 +317,22 @@ func (p *Proxy) handleSignatureRoute(ctx *gin.Context, targetRoute string) bool
 }
 
 func (p *Proxy) handleBrokerError(ctx *gin.Context, err error, context string) {
+	var rateLimitErr *ctrl.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		p.logger.Warnf("Proxy broker: %v, context: %s", err, context)
+		ctx.Header("Retry-After", strconv.FormatInt(rateLimitErr.RetryAfterSeconds(), 10))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	var admissionErr *ctrl.AdmissionError
+	if errors.As(err, &admissionErr) {
+		p.logger.Warnf("Proxy broker: %v, context: %s", err, context)
+		ctx.Header("Retry-After", strconv.FormatInt(admissionErr.RetryAfterSeconds(), 10))
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
 	p.logger.Errorf("Proxy broker error: %v, context: %s", err, context)
 	info := "Provider proxy: handle proxied service"
 	if context != "" {
@@ -0,0 +1,146 @@
+package tokenizer
+
+import (
+	"container/heap"
+	"encoding/json"
+	"strings"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+)
+
+// bpe holds the vocabulary and merge ranks shared by both BPE flavours
+type bpe struct {
+	vocab map[string]int
+	ranks map[[2]string]int
+	unkID int
+}
+
+func newBPE(file tokenizerFile) (*bpe, error) {
+	vocab := map[string]int{}
+	if err := json.Unmarshal(file.Model.Vocab, &vocab); err != nil {
+		return nil, errors.Wrap(err, "parse BPE vocab")
+	}
+
+	// Merges are either "a b" strings or ["a", "b"] pairs depending on the tokenizers version
+	var pairs [][2]string
+	if err := json.Unmarshal(file.Model.Merges, &pairs); err != nil {
+		var merges []string
+		if err := json.Unmarshal(file.Model.Merges, &merges); err != nil {
+			return nil, errors.Wrap(err, "parse BPE merges")
+		}
+		for _, merge := range merges {
+			left, right, ok := strings.Cut(merge, " ")
+			if !ok {
+				continue
+			}
+			pairs = append(pairs, [2]string{left, right})
+		}
+	}
+
+	ranks := make(map[[2]string]int, len(pairs))
+	for i, pair := range pairs {
+		ranks[pair] = i
+	}
+
+	unkID := -1
+	if file.Model.UnkToken != nil {
+		if id, ok := vocab[*file.Model.UnkToken]; ok {
+			unkID = id
+		}
+	}
+
+	return &bpe{vocab: vocab, ranks: ranks, unkID: unkID}, nil
+}
+
+// merge applies the lowest ranked merge until none applies any more, the leftmost one first among
+// equal ranks. Symbols form a linked list and the candidate merges a heap, so that a word of n
+// symbols is merged in O(n log n)
+func (b *bpe) merge(symbols []string) []string {
+	if len(symbols) < 2 {
+		return symbols
+	}
+	nodes := make([]mergeNode, len(symbols))
+	for i, symbol := range symbols {
+		nodes[i] = mergeNode{symbol: symbol, prev: i - 1, next: i + 1}
+	}
+	nodes[len(nodes)-1].next = -1
+
+	candidates := &mergeHeap{}
+	push := func(left int) {
+		if left < 0 || nodes[left].next < 0 {
+			return
+		}
+		right := nodes[left].next
+		if rank, ok := b.ranks[[2]string{nodes[left].symbol, nodes[right].symbol}]; ok {
+			heap.Push(candidates, mergeCandidate{rank: rank, left: left})
+		}
+	}
+	for i := range nodes {
+		push(i)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(mergeCandidate)
+		left := &nodes[c.left]
+		if left.merged || left.next < 0 {
+			continue
+		}
+		right := &nodes[left.next]
+		// A candidate is stale once either symbol was merged again, pairs have distinct ranks
+		if rank, ok := b.ranks[[2]string{left.symbol, right.symbol}]; !ok || rank != c.rank {
+			continue
+		}
+		left.symbol += right.symbol
+		right.merged = true
+		left.next = right.next
+		if right.next >= 0 {
+			nodes[right.next].prev = c.left
+		}
+		push(left.prev)
+		push(c.left)
+	}
+
+	merged := symbols[:0]
+	for i := 0; i >= 0; i = nodes[i].next {
+		merged = append(merged, nodes[i].symbol)
+	}
+	return merged
+}
+
+type mergeNode struct {
+	symbol     string
+	prev, next int
+	merged     bool
+}
+
+type mergeCandidate struct {
+	rank int
+	// left is the index of the left symbol, which orders equal ranks from left to right
+	left int
+}
+
+type mergeHeap []mergeCandidate
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].left < h[j].left
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(mergeCandidate)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func splitRunes(text string) []string {
+	symbols := make([]string, 0, len(text))
+	for _, r := range text {
+		symbols = append(symbols, string(r))
+	}
+	return symbols
+}
@@ -0,0 +1,141 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// byteLevelBPE is the GPT-2 style tokenizer: text is split into words, every byte of a word is
+// mapped to a printable rune and the result is merged with BPE
+type byteLevelBPE struct {
+	bpe   *bpe
+	added addedTokens
+	cache *lru.Cache[string, []int]
+}
+
+var byteEncoder = bytesToUnicode()
+
+// bytesToUnicode maps every byte to a printable rune, the same table as GPT-2's bytes_to_unicode
+func bytesToUnicode() [256]rune {
+	var table [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = rune(b)
+			continue
+		}
+		table[b] = rune(256 + n)
+		n++
+	}
+	return table
+}
+
+func (t *byteLevelBPE) Encode(text string) []int {
+	var ids []int
+	t.split(text, func(word string) {
+		ids = append(ids, encodeCached(t.cache, word, t.encodeWord)...)
+	}, func(id int) {
+		ids = append(ids, id)
+	})
+	return ids
+}
+
+// CountTokens counts the words longer than maxWordBytes by their byte length, an upper bound of
+// their tokens
+func (t *byteLevelBPE) CountTokens(text string) int {
+	count := 0
+	t.split(text, func(word string) {
+		if len(word) > maxWordBytes {
+			count += len(word)
+			return
+		}
+		count += len(encodeCached(t.cache, word, t.encodeWord))
+	}, func(int) {
+		count++
+	})
+	return count
+}
+
+// split cuts text into added tokens and the words in between
+func (t *byteLevelBPE) split(text string, word func(word string), added func(id int)) {
+	t.added.split(text, func(segment string) {
+		for _, w := range splitWords(segment) {
+			word(w)
+		}
+	}, added)
+}
+
+func (t *byteLevelBPE) encodeWord(word string) []int {
+	var ids []int
+	symbols := make([]string, 0, len(word))
+	for i := 0; i < len(word); i++ {
+		symbols = append(symbols, string(byteEncoder[word[i]]))
+	}
+	for _, symbol := range t.bpe.merge(symbols) {
+		if id, ok := t.bpe.vocab[symbol]; ok {
+			ids = append(ids, id)
+		} else if t.bpe.unkID >= 0 {
+			ids = append(ids, t.bpe.unkID)
+		}
+	}
+	return ids
+}
+
+var contractions = []string{"s", "t", "re", "ve", "m", "ll", "d"}
+
+// splitWords follows the GPT-2 pre-tokenizer pattern
+// 's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+// which cannot be expressed with Go's regexp because of the lookahead. It is used for Llama 3 and
+// Qwen as well, whose patterns split numbers and CJK text differently
+func splitWords(text string) []string {
+	runes := []rune(text)
+	var words []string
+	for i := 0; i < len(runes); {
+		j := matchWord(runes, i)
+		words = append(words, string(runes[i:j]))
+		i = j
+	}
+	return words
+}
+
+func matchWord(r []rune, start int) int {
+	if r[start] == '\'' {
+		rest := strings.ToLower(string(r[start+1 : min(start+3, len(r))]))
+		for _, c := range contractions {
+			if strings.HasPrefix(rest, c) {
+				return start + 1 + len(c)
+			}
+		}
+	}
+
+	i := start
+	if r[i] == ' ' && i+1 < len(r) && !unicode.IsSpace(r[i+1]) {
+		i++
+	}
+	switch {
+	case unicode.IsLetter(r[i]):
+		return consume(r, i, unicode.IsLetter)
+	case unicode.IsNumber(r[i]):
+		return consume(r, i, unicode.IsNumber)
+	case !unicode.IsSpace(r[i]):
+		return consume(r, i, func(c rune) bool {
+			return !unicode.IsSpace(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+		})
+	}
+
+	// A whitespace run leaves its last rune to the following word
+	end := consume(r, start, unicode.IsSpace)
+	if end < len(r) && end-start > 1 {
+		return end - 1
+	}
+	return end
+}
+
+func consume(r []rune, i int, match func(rune) bool) int {
+	for i < len(r) && match(r[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,109 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// sentencePieceSpace replaces spaces in SentencePiece vocabularies
+const sentencePieceSpace = "▁"
+
+// sentencePieceBPE is the Llama 2 / Mistral style tokenizer: spaces become ▁, a ▁ is prepended,
+// runes are merged with BPE and unknown runes fall back to their UTF-8 bytes
+type sentencePieceBPE struct {
+	bpe          *bpe
+	added        addedTokens
+	byteFallback bool
+	cache        *lru.Cache[string, []int]
+}
+
+func (t *sentencePieceBPE) Encode(text string) []int {
+	var ids []int
+	t.split(text, func(piece string) {
+		ids = append(ids, encodeCached(t.cache, piece, t.encodePiece)...)
+	}, func(id int) {
+		ids = append(ids, id)
+	})
+	return ids
+}
+
+// CountTokens counts the pieces longer than maxWordBytes by their byte length, the tokens of their
+// byte fallback
+func (t *sentencePieceBPE) CountTokens(text string) int {
+	count := 0
+	t.split(text, func(piece string) {
+		if len(piece) > maxWordBytes {
+			count += len(piece)
+			return
+		}
+		count += len(encodeCached(t.cache, piece, t.encodePiece))
+	}, func(int) {
+		count++
+	})
+	return count
+}
+
+// split cuts text into added tokens and the pieces in between
+func (t *sentencePieceBPE) split(text string, piece func(piece string), added func(id int)) {
+	first := true
+	t.added.split(text, func(segment string) {
+		// Only the start of the text gets the dummy prefix
+		if first {
+			segment = " " + segment
+			first = false
+		}
+		for _, p := range splitPieces(strings.ReplaceAll(segment, " ", sentencePieceSpace)) {
+			piece(p)
+		}
+	}, added)
+}
+
+func (t *sentencePieceBPE) encodePiece(piece string) []int {
+	var ids []int
+	for _, symbol := range t.bpe.merge(splitRunes(piece)) {
+		if id, ok := t.bpe.vocab[symbol]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		ids = append(ids, t.fallback(symbol)...)
+	}
+	return ids
+}
+
+func (t *sentencePieceBPE) fallback(symbol string) []int {
+	if !t.byteFallback {
+		if t.bpe.unkID >= 0 {
+			return []int{t.bpe.unkID}
+		}
+		return nil
+	}
+	ids := make([]int, 0, len(symbol))
+	for i := 0; i < len(symbol); i++ {
+		if id, ok := t.bpe.vocab[fmt.Sprintf("<0x%02X>", symbol[i])]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// splitPieces cuts text before every ▁ that follows another rune, so that pieces are merged and
+// cached per word instead of over the whole text. Leading ▁ runs stay attached to their word.
+func splitPieces(text string) []string {
+	var pieces []string
+	start := 0
+	prevSpace := true
+	for i, r := range text {
+		isSpace := string(r) == sentencePieceSpace
+		if isSpace && !prevSpace {
+			pieces = append(pieces, text[start:i])
+			start = i
+		}
+		prevSpace = isSpace
+	}
+	if start < len(text) {
+		pieces = append(pieces, text[start:])
+	}
+	return pieces
+}
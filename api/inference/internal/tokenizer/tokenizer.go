@@ -0,0 +1,140 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/0glabs/0g-serving-broker/common/errors"
+)
+
+// Tokenizer converts text into the token ids a model sees
+type Tokenizer interface {
+	Encode(text string) []int
+	CountTokens(text string) int
+}
+
+// tokenizerFile is the subset of a Hugging Face tokenizer.json used by the broker
+type tokenizerFile struct {
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+	Normalizer   json.RawMessage `json:"normalizer"`
+	PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+	Model        struct {
+		Type         string          `json:"type"`
+		Vocab        json.RawMessage `json:"vocab"`
+		Merges       json.RawMessage `json:"merges"`
+		ByteFallback bool            `json:"byte_fallback"`
+		UnkToken     *string         `json:"unk_token"`
+	} `json:"model"`
+}
+
+const (
+	// maxWordBytes bounds the words CountTokens encodes with BPE. A longer word, e.g. a long run of
+	// text without spaces, is counted by its byte length, like when no tokenizer is configured, so
+	// that counting the tokens of a request before its balance is checked stays cheap
+	maxWordBytes = 1024
+	// wordCacheSize is the number of word encodings a tokenizer keeps
+	wordCacheSize = 8192
+)
+
+// Load reads a Hugging Face tokenizer.json and returns the matching implementation, byte-level BPE
+// (GPT-2, Llama 3, Qwen) or SentencePiece BPE (Llama 2, Mistral). Llama 3 and Qwen split words with
+// another pattern than GPT-2, their counts are approximate
+func Load(path string) (Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read tokenizer file")
+	}
+
+	var file tokenizerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "parse tokenizer file")
+	}
+	if file.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported tokenizer model type %q", file.Model.Type)
+	}
+
+	model, err := newBPE(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := lru.New[string, []int](wordCacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "create word cache")
+	}
+	added := newAddedTokens(file)
+	switch {
+	case bytes.Contains(file.PreTokenizer, []byte(`"ByteLevel"`)):
+		return &byteLevelBPE{bpe: model, added: added, cache: cache}, nil
+	case bytes.Contains(file.PreTokenizer, []byte(`"Metaspace"`)),
+		bytes.Contains(file.Normalizer, []byte(sentencePieceSpace)):
+		return &sentencePieceBPE{bpe: model, added: added, byteFallback: file.Model.ByteFallback, cache: cache}, nil
+	default:
+		return nil, errors.New("unsupported tokenizer pre-tokenizer, expect ByteLevel or Metaspace")
+	}
+}
+
+// addedTokens are matched verbatim before the model runs, e.g. chat template markers
+type addedTokens struct {
+	// contents is sorted by length, longest first, so that the longest token wins
+	contents []string
+	ids      map[string]int
+}
+
+func newAddedTokens(file tokenizerFile) addedTokens {
+	added := addedTokens{ids: make(map[string]int, len(file.AddedTokens))}
+	for _, token := range file.AddedTokens {
+		if token.Content == "" {
+			continue
+		}
+		added.contents = append(added.contents, token.Content)
+		added.ids[token.Content] = token.ID
+	}
+	sort.Slice(added.contents, func(i, j int) bool {
+		return len(added.contents[i]) > len(added.contents[j])
+	})
+	return added
+}
+
+// split cuts text around added tokens, calling plain for the plain segments in between and added
+// for the added tokens, in order
+func (a addedTokens) split(text string, plain func(segment string), added func(id int)) {
+	for text != "" {
+		pos, token := -1, ""
+		for _, content := range a.contents {
+			if i := strings.Index(text, content); i >= 0 && (pos < 0 || i < pos) {
+				pos, token = i, content
+			}
+		}
+		if pos < 0 {
+			plain(text)
+			return
+		}
+		if pos > 0 {
+			plain(text[:pos])
+		}
+		added(a.ids[token])
+		text = text[pos+len(token):]
+	}
+}
+
+// encodeCached encodes a word with encode, the encodings of the words up to maxWordBytes are cached
+func encodeCached(cache *lru.Cache[string, []int], word string, encode func(word string) []int) []int {
+	if ids, ok := cache.Get(word); ok {
+		return ids
+	}
+	ids := encode(word)
+	if len(word) <= maxWordBytes {
+		cache.Add(word, ids)
+	}
+	return ids
+}
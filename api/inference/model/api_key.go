@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+)
+
+// APIKey is minted by a user to call the proxy without signing session tokens. Only the hash of
+// the key is stored, the key itself is returned once when it is minted
+type APIKey struct {
+	Model
+	ID      string `gorm:"type:varchar(64);not null;primaryKey" json:"id"`
+	KeyHash string `gorm:"type:varchar(66);not null;uniqueIndex" json:"-"`
+	// GrantHash is the hash of the signed grant the key was minted from, a grant mints one key only
+	GrantHash string `gorm:"type:varchar(66);not null;uniqueIndex" json:"-"`
+	User      string `gorm:"type:varchar(255);not null;index" json:"user"`
+	Name      string `gorm:"type:varchar(255);not null;default:''" json:"name"`
+	// SpendingCap bounds the fees billed to the key in neuron, nil means no cap
+	SpendingCap *util.Amount `gorm:"type:decimal(65,0)" json:"spendingCap"`
+	Spent       util.Amount  `gorm:"type:decimal(65,0);not null;default:0" json:"spent"`
+	// Models lists the models the key may call, empty means every model
+	Models    StringSlice `gorm:"type:json;not null;default:('[]')" json:"models"`
+	ExpiresAt time.Time   `gorm:"not null" json:"expiresAt"`
+	Revoked   *bool       `gorm:"type:tinyint(1);not null;default:0" json:"revoked"`
+}
+
+type APIKeyList struct {
+	Metadata ListMeta `json:"metadata"`
+	Items    []APIKey `json:"items"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+)
+
+// BalanceHold reserves part of the locked balance of a user for a request in flight, so that
+// concurrent requests cannot together spend more than the deposit. It is deleted when the fee of
+// the request is billed or when the request fails
+type BalanceHold struct {
+	Model
+	RequestHash string      `gorm:"type:varchar(255);not null;primaryKey" json:"requestHash"`
+	User        string      `gorm:"type:varchar(255);not null;index" json:"user"`
+	Amount      util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"amount"`
+	// SessionNonce is the session of the request, the holds of a session count towards its cap
+	SessionNonce string `gorm:"type:varchar(255);not null;default:'';index" json:"sessionNonce,omitempty"`
+	// APIKeyID is the API key of the request, the holds of a key count towards its cap
+	APIKeyID string `gorm:"type:varchar(64);not null;default:'';index" json:"apiKeyId,omitempty"`
+	// ExpiresAt bounds the hold of a request whose broker stopped before releasing it
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+}
@@ -10,6 +10,83 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ================================= APIKey =================================
+func (d *APIKey) Bind(ctx *gin.Context) error {
+	var r APIKey
+	if err := ctx.ShouldBindJSON(&r); err != nil {
+		return err
+	}
+	d.ID = r.ID
+	d.User = r.User
+	d.Name = r.Name
+	d.SpendingCap = r.SpendingCap
+	d.Spent = r.Spent
+	d.Models = r.Models
+	d.ExpiresAt = r.ExpiresAt
+	d.Revoked = r.Revoked
+
+	return nil
+}
+
+func (d *APIKey) BindWithReadonly(ctx *gin.Context, old APIKey) error {
+	if err := d.Bind(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ================================= BalanceHold =================================
+func (d *BalanceHold) Bind(ctx *gin.Context) error {
+	var r BalanceHold
+	if err := ctx.ShouldBindJSON(&r); err != nil {
+		return err
+	}
+	d.RequestHash = r.RequestHash
+	d.User = r.User
+	d.Amount = r.Amount
+	d.SessionNonce = r.SessionNonce
+	d.APIKeyID = r.APIKeyID
+	d.ExpiresAt = r.ExpiresAt
+
+	return nil
+}
+
+func (d *BalanceHold) BindWithReadonly(ctx *gin.Context, old BalanceHold) error {
+	if err := d.Bind(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ================================= ChatSignature =================================
+func (d *ChatSignature) Bind(ctx *gin.Context) error {
+	var r ChatSignature
+	if err := ctx.ShouldBindJSON(&r); err != nil {
+		return err
+	}
+	d.ChatID = r.ChatID
+	d.RequestHash = r.RequestHash
+	d.Text = r.Text
+	d.Signature = r.Signature
+	d.SigningAddress = r.SigningAddress
+	d.SigningAlgo = r.SigningAlgo
+	d.InputCount = r.InputCount
+	d.OutputCount = r.OutputCount
+	d.Fee = r.Fee
+
+	return nil
+}
+
+func (d *ChatSignature) BindWithReadonly(ctx *gin.Context, old ChatSignature) error {
+	if err := d.Bind(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // ================================= Request =================================
 func (d *Request) Bind(ctx *gin.Context) error {
 	var r Request
@@ -30,6 +107,13 @@ func (d *Request) Bind(ctx *gin.Context) error {
 	d.InputCount = r.InputCount
 	d.OutputCount = r.OutputCount
 	d.SkipUntil = r.SkipUntil
+	d.UsageSource = r.UsageSource
+	d.Truncated = r.Truncated
+	d.APIKeyID = r.APIKeyID
+	d.SessionNonce = r.SessionNonce
+	d.InputPrice = r.InputPrice
+	d.OutputPrice = r.OutputPrice
+	d.PriceUnit = r.PriceUnit
 
 	return nil
 }
@@ -67,6 +151,68 @@ func (d *Service) BindWithReadonly(ctx *gin.Context, old Service) error {
 	return nil
 }
 
+// ================================= Session =================================
+func (d *Session) Bind(ctx *gin.Context) error {
+	var r Session
+	if err := ctx.ShouldBindJSON(&r); err != nil {
+		return err
+	}
+	d.Address = r.Address
+	d.Nonce = r.Nonce
+	d.TokenHash = r.TokenHash
+	d.ExpiresAt = r.ExpiresAt
+	d.Revoked = r.Revoked
+	d.Spent = r.Spent
+
+	return nil
+}
+
+func (d *Session) BindWithReadonly(ctx *gin.Context, old Session) error {
+	if err := d.Bind(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ================================= UsageRecord =================================
+func (d *UsageRecord) Bind(ctx *gin.Context) error {
+	var r UsageRecord
+	if err := ctx.ShouldBindJSON(&r); err != nil {
+		return err
+	}
+	d.RequestHash = r.RequestHash
+	d.UserAddress = r.UserAddress
+	d.Nonce = r.Nonce
+	d.ServiceName = r.ServiceName
+	d.InputCount = r.InputCount
+	d.OutputCount = r.OutputCount
+	d.InputPrice = r.InputPrice
+	d.OutputPrice = r.OutputPrice
+	d.PriceUnit = r.PriceUnit
+	d.InputFee = r.InputFee
+	d.OutputFee = r.OutputFee
+	d.Fee = r.Fee
+	d.UsageSource = r.UsageSource
+	d.Truncated = r.Truncated
+	d.APIKeyID = r.APIKeyID
+	d.SessionNonce = r.SessionNonce
+	d.RequestedAt = r.RequestedAt
+	d.SettledAt = r.SettledAt
+	d.TxHash = r.TxHash
+	d.BatchID = r.BatchID
+
+	return nil
+}
+
+func (d *UsageRecord) BindWithReadonly(ctx *gin.Context, old UsageRecord) error {
+	if err := d.Bind(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // ================================= User =================================
 func (d *User) Bind(ctx *gin.Context) error {
 	var r User
@@ -90,6 +236,28 @@ func (d *User) BindWithReadonly(ctx *gin.Context, old User) error {
 	return nil
 }
 
+// ================================= UserRateLimit =================================
+func (d *UserRateLimit) Bind(ctx *gin.Context) error {
+	var r UserRateLimit
+	if err := ctx.ShouldBindJSON(&r); err != nil {
+		return err
+	}
+	d.User = r.User
+	d.RequestsPerMinute = r.RequestsPerMinute
+	d.TokensPerMinute = r.TokensPerMinute
+	d.MaxConcurrentStreams = r.MaxConcurrentStreams
+
+	return nil
+}
+
+func (d *UserRateLimit) BindWithReadonly(ctx *gin.Context, old UserRateLimit) error {
+	if err := d.Bind(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 //=============== implementation of sql.scanner and sql.valuer  ===============
 func (m StringSlice) Value() (driver.Value, error) {
 	return json.Marshal(m)
@@ -0,0 +1,11 @@
+package model
+
+// UserRateLimit overrides the default rate limits of the broker for one user address.
+// A nil limit falls back to the default, and 0 removes the limit
+type UserRateLimit struct {
+	Model
+	User                 string `gorm:"type:varchar(255);not null;primaryKey" json:"user"`
+	RequestsPerMinute    *int64 `gorm:"type:bigint" json:"requestsPerMinute"`
+	TokensPerMinute      *int64 `gorm:"type:bigint" json:"tokensPerMinute"`
+	MaxConcurrentStreams *int64 `gorm:"type:bigint" json:"maxConcurrentStreams"`
+}
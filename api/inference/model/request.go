@@ -1,15 +1,19 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+)
 
 type Request struct {
 	Model
 	UserAddress  string `gorm:"type:varchar(255);not null;uniqueIndex:processed_userAddress_nonce" json:"userAddress" binding:"required" immutable:"true"`
 	Nonce        string `gorm:"type:varchar(255);not null;index:processed_userAddress_nonce" json:"nonce" binding:"required" immutable:"true"`
 	ServiceName  string `gorm:"type:varchar(255);not null" json:"serviceName" binding:"required" immutable:"true"`
-	InputFee     string `gorm:"type:varchar(255);not null" json:"inputFee" binding:"required" immutable:"true"`
-	OutputFee    string `gorm:"type:varchar(255);not null" json:"outputFee" binding:"required" immutable:"true"`
-	Fee          string `gorm:"type:varchar(255);not null" json:"fee" binding:"required" immutable:"true"`
+	InputFee     util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"inputFee" immutable:"true"`
+	OutputFee    util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"outputFee" immutable:"true"`
+	Fee          util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"fee" immutable:"true"`
 	Signature    string `gorm:"type:varchar(255);not null" json:"signature" binding:"required" immutable:"true"`
 	TeeSignature string `gorm:"type:varchar(255);not null" json:"teeSignature" binding:"required" immutable:"true"`
 	RequestHash  string `gorm:"type:varchar(255);not null;primaryKey" json:"requestHash" binding:"required" immutable:"true"`
@@ -20,12 +24,34 @@ type Request struct {
 	OutputCount  int64      `gorm:"type:bigint;not null;default:0" json:"outputCount"`
 	// Skip this request in settlement until this time
 	SkipUntil    *time.Time `gorm:"type:datetime;index" json:"skipUntil,omitempty"`
+	// Where the billed token counts come from, one of the UsageSource constants
+	UsageSource  string     `gorm:"type:varchar(32);not null;default:''" json:"usageSource"`
+	// Set when the client disconnected mid-stream and only the output already streamed was billed
+	Truncated    bool       `gorm:"type:tinyint(1);not null;default:0" json:"truncated"`
+	// Set when the request was authenticated with a broker-issued API key instead of a session token
+	APIKeyID     string     `gorm:"type:varchar(64);not null;default:'';index" json:"apiKeyId,omitempty"`
+	// Nonce of the session token the request was authorized with, its fees count against the session cap
+	SessionNonce string     `gorm:"type:varchar(255);not null;default:''" json:"sessionNonce,omitempty"`
+	// Unit prices of the service when the request was created, its fee is billed at these prices
+	InputPrice   util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"inputPrice" immutable:"true"`
+	OutputPrice  util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"outputPrice" immutable:"true"`
+	// PriceUnit is the number of tokens the prices are quoted for
+	PriceUnit    int64      `gorm:"type:bigint;not null;default:1" json:"priceUnit" immutable:"true"`
 }
 
+const (
+	// UsageSourceReported means the counts were reported by the model in its usage block
+	UsageSourceReported = "reported"
+	// UsageSourceTokenized means the counts were computed by running the model tokenizer
+	UsageSourceTokenized = "tokenized"
+	// UsageSourceEstimated means no tokenizer was available and the counts are a rough estimate
+	UsageSourceEstimated = "estimated"
+)
+
 type RequestList struct {
 	Metadata ListMeta  `json:"metadata"`
 	Items    []Request `json:"items"`
-	Fee      int       `json:"fee"`
+	Fee      util.Amount `json:"fee"`
 }
 
 type RequestListOptions struct {
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+)
+
+// Session records the nonce of a session token the first time it is used, so that the nonce
+// cannot be reused by another token and the session can be revoked by its user
+type Session struct {
+	Model
+	Address string `gorm:"type:varchar(255);not null;primaryKey" json:"address"`
+	Nonce   string `gorm:"type:varchar(255);not null;primaryKey" json:"nonce"`
+	// TokenHash is the keccak256 of the session token, empty when the nonce was revoked before any use
+	TokenHash string    `gorm:"type:varchar(66);not null;default:''" json:"tokenHash"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	Revoked   *bool     `gorm:"type:tinyint(1);not null;default:0" json:"revoked"`
+	// Spent is the sum of the fees billed to the session in neuron
+	Spent util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"spent"`
+}
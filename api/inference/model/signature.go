@@ -0,0 +1,17 @@
+package model
+
+// ChatSignature is the provider signature over a request and its response, kept for the
+// signature retention period so that users can verify responses later
+type ChatSignature struct {
+	Model
+	ChatID         string `gorm:"type:varchar(255);not null;primaryKey" json:"chatID"`
+	RequestHash    string `gorm:"type:varchar(255);not null;index" json:"requestHash"`
+	Text           string `gorm:"type:text;not null" json:"text"`
+	Signature      string `gorm:"type:varchar(255);not null" json:"signature"`
+	SigningAddress string `gorm:"type:varchar(255);not null" json:"signingAddress"`
+	SigningAlgo    string `gorm:"type:varchar(32);not null" json:"signingAlgo"`
+	// The billed usage the signature commits to
+	InputCount  int64  `gorm:"type:bigint;not null;default:0" json:"inputCount"`
+	OutputCount int64  `gorm:"type:bigint;not null;default:0" json:"outputCount"`
+	Fee         string `gorm:"type:varchar(255);not null;default:'0'" json:"fee"`
+}
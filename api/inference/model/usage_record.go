@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
+)
+
+// UsageRecord is the ledger entry of a settled request. It replaces the request row once the fee
+// is settled on-chain and is never updated, so that the billing of a user can be audited after
+// the settlement, until the usage retention period elapses
+type UsageRecord struct {
+	Model
+	RequestHash string `gorm:"type:varchar(255);not null;primaryKey" json:"requestHash"`
+	UserAddress string `gorm:"type:varchar(255);not null;index:user_settledAt" json:"userAddress"`
+	Nonce       string `gorm:"type:varchar(255);not null" json:"nonce"`
+	ServiceName string `gorm:"type:varchar(255);not null" json:"serviceName"`
+	InputCount  int64  `gorm:"type:bigint;not null;default:0" json:"inputCount"`
+	OutputCount int64  `gorm:"type:bigint;not null;default:0" json:"outputCount"`
+	// Unit prices the request was billed at, per PriceUnit tokens
+	InputPrice  util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"inputPrice"`
+	OutputPrice util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"outputPrice"`
+	PriceUnit   int64       `gorm:"type:bigint;not null;default:1" json:"priceUnit"`
+	InputFee    util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"inputFee"`
+	OutputFee   util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"outputFee"`
+	Fee         util.Amount `gorm:"type:decimal(65,0);not null;default:0" json:"fee"`
+	// Where the billed token counts come from, one of the UsageSource constants
+	UsageSource  string `gorm:"type:varchar(32);not null;default:''" json:"usageSource"`
+	Truncated    bool   `gorm:"type:tinyint(1);not null;default:0" json:"truncated"`
+	APIKeyID     string `gorm:"type:varchar(64);not null;default:''" json:"apiKeyId,omitempty"`
+	SessionNonce string `gorm:"type:varchar(255);not null;default:''" json:"sessionNonce,omitempty"`
+	// RequestedAt is the creation time of the request
+	RequestedAt *time.Time `json:"requestedAt"`
+	SettledAt   time.Time  `gorm:"not null;index:user_settledAt;index" json:"settledAt"`
+	// TxHash is the settleFeesWithTEE transaction, BatchID the settlement round it was sent in
+	TxHash  string `gorm:"type:varchar(66);not null;index" json:"txHash"`
+	BatchID string `gorm:"type:varchar(64);not null;index" json:"batchId"`
+}
+
+type UsageRecordList struct {
+	Metadata ListMeta      `json:"metadata"`
+	Items    []UsageRecord `json:"items"`
+	// Totals of every record matching the options, not only of the page returned
+	InputCount  int64       `json:"inputCount"`
+	OutputCount int64       `json:"outputCount"`
+	Fee         util.Amount `json:"fee"`
+}
+
+type UsageRecordListOptions struct {
+	User    string `form:"user"`
+	TxHash  string `form:"txHash"`
+	BatchID string `form:"batchId"`
+	// From and To bound the settlement time, RFC 3339
+	From   *time.Time `form:"from"`
+	To     *time.Time `form:"to"`
+	Limit  int        `form:"limit"`
+	Offset int        `form:"offset"`
+}
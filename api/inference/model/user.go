@@ -4,12 +4,14 @@ import (
 	"time"
 
 	"gorm.io/plugin/soft_delete"
+
+	"github.com/0glabs/0g-serving-broker/common/util"
 )
 
 type User struct {
 	Model
 	User                 string                `gorm:"type:varchar(255);not null;index:deleted_user_provider" json:"user" binding:"required" immutable:"true"`
-	LockBalance          *string               `gorm:"type:varchar(255);not null;default:0" json:"lockBalance"`
+	LockBalance          *util.Amount          `gorm:"type:decimal(65,0);not null;default:0" json:"lockBalance"`
 	LastBalanceCheckTime *time.Time            `json:"lastBalanceCheckTime"`
 	Signer               StringSlice           `gorm:"type:json;not null;default:('[]')" json:"signer"`
 	SkipUntil            *time.Time            `gorm:"type:datetime;index" json:"skipUntil,omitempty"`
@@ -23,6 +25,6 @@ type UserList struct {
 
 type UserListOptions struct {
 	LowBalanceRisk         *time.Time
-	MinUnsettledFee        *int64
-	SettleTriggerThreshold *int64
+	MinUnsettledFee        *util.Amount
+	SettleTriggerThreshold *util.Amount
 }
@@ -39,6 +39,15 @@ func ValidateUpdateRequest(oldVal, newVal Request) error {
 	if !apiequality.Semantic.DeepEqual(newVal.RequestHash, oldVal.RequestHash){
 		fields = append(fields, "requestHash")
 	}
+	if !apiequality.Semantic.DeepEqual(newVal.InputPrice, oldVal.InputPrice){
+		fields = append(fields, "inputPrice")
+	}
+	if !apiequality.Semantic.DeepEqual(newVal.OutputPrice, oldVal.OutputPrice){
+		fields = append(fields, "outputPrice")
+	}
+	if !apiequality.Semantic.DeepEqual(newVal.PriceUnit, oldVal.PriceUnit){
+		fields = append(fields, "priceUnit")
+	}
 
 	if len(fields) > 0 {
 		return fmt.Errorf("update field: [%s] not allowed", strings.Join(fields, ","))
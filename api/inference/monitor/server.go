@@ -12,6 +12,16 @@ var (
 	RequestCount    *prometheus.CounterVec
 	ErrorCount      *prometheus.CounterVec
 	RequestDuration *prometheus.HistogramVec
+
+	UpstreamConnections *prometheus.GaugeVec
+	UpstreamInFlight    *prometheus.GaugeVec
+	UpstreamConnReuse   *prometheus.CounterVec
+	UpstreamRetryCount  *prometheus.CounterVec
+
+	AdmissionQueueLength   *prometheus.GaugeVec
+	AdmissionQueuePosition *prometheus.HistogramVec
+	AdmissionWaitDuration  *prometheus.HistogramVec
+	AdmissionRejectedCount *prometheus.CounterVec
 )
 
 func PrometheusInit(serverName string) {
@@ -47,9 +57,91 @@ func PrometheusInit(serverName string) {
 		[]string{"path"},
 	)
 
+	UpstreamConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "broker_upstream_connections",
+			Help:        "Number of open connections to the backend, labeled by model.",
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model"},
+	)
+
+	UpstreamInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "broker_upstream_in_flight_requests",
+			Help:        "Number of requests to the backend in flight, labeled by model.",
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model"},
+	)
+
+	UpstreamConnReuse = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "broker_upstream_conn_acquired_total",
+			Help:        "Total number of connections acquired for backend requests, labeled by model and whether the connection was reused from the pool.",
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model", "reused"},
+	)
+
+	UpstreamRetryCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "broker_upstream_retries_total",
+			Help:        "Total number of retried backend requests, labeled by model.",
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model"},
+	)
+
+	AdmissionQueueLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "broker_admission_queue_length",
+			Help:        "Number of requests waiting for admission to the backend, labeled by model.",
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model"},
+	)
+
+	AdmissionQueuePosition = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "broker_admission_queue_position",
+			Help:        "Histogram of the queue position of requests when they are queued, labeled by model.",
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 10),
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model"},
+	)
+
+	AdmissionWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "broker_admission_wait_duration_seconds",
+			Help:        "Histogram of the time requests wait for admission to the backend, labeled by model.",
+			Buckets:     prometheus.DefBuckets,
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model"},
+	)
+
+	AdmissionRejectedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "broker_admission_rejected_total",
+			Help:        "Total number of requests rejected by the admission queue, labeled by model and reason.",
+			ConstLabels: prometheus.Labels{"server": serverName},
+		},
+		[]string{"model", "reason"},
+	)
+
 	prometheus.MustRegister(RequestCount)
 	prometheus.MustRegister(ErrorCount)
 	prometheus.MustRegister(RequestDuration)
+	prometheus.MustRegister(UpstreamConnections)
+	prometheus.MustRegister(UpstreamInFlight)
+	prometheus.MustRegister(UpstreamConnReuse)
+	prometheus.MustRegister(UpstreamRetryCount)
+	prometheus.MustRegister(AdmissionQueueLength)
+	prometheus.MustRegister(AdmissionQueuePosition)
+	prometheus.MustRegister(AdmissionWaitDuration)
+	prometheus.MustRegister(AdmissionRejectedCount)
 }
 
 // TrackMetrics is a Gin middleware that tracks request metrics.